@@ -0,0 +1,41 @@
+package fixenv
+
+// benchmarkT is optional extension of T, testing.B implement it.
+// Env stop benchmark timer while fixture function executed on cache miss,
+// then fixtures setup time not included to benchmark result.
+type benchmarkT interface {
+	StartTimer()
+	StopTimer()
+}
+
+// stopBenchmarkTimer stop timer of benchmark for outer fixture call.
+// It return true if the timer was stopped and must be started by startBenchmarkTimer.
+func (e *EnvT) stopBenchmarkTimer(options CacheOptions) bool {
+	if options.KeepBenchmarkTimer {
+		return false
+	}
+	bt, ok := e.t.(benchmarkT)
+	if !ok {
+		return false
+	}
+
+	e.benchmarkM.Lock()
+	defer e.benchmarkM.Unlock()
+
+	// nested fixtures call must not start timer before outer fixture finished
+	e.benchmarkTimerStops++
+	if e.benchmarkTimerStops == 1 {
+		bt.StopTimer()
+	}
+	return true
+}
+
+func (e *EnvT) startBenchmarkTimer() {
+	e.benchmarkM.Lock()
+	defer e.benchmarkM.Unlock()
+
+	e.benchmarkTimerStops--
+	if e.benchmarkTimerStops == 0 {
+		e.t.(benchmarkT).StartTimer()
+	}
+}
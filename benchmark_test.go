@@ -0,0 +1,116 @@
+package fixenv
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/rekby/fixenv/internal"
+)
+
+type benchmarkMock struct {
+	internal.TestMock
+	timerOn    bool
+	stopCalls  int
+	startCalls int
+}
+
+func (b *benchmarkMock) StartTimer() {
+	b.startCalls++
+	b.timerOn = true
+}
+
+func (b *benchmarkMock) StopTimer() {
+	b.stopCalls++
+	b.timerOn = false
+}
+
+func TestBenchmarkTimer(t *testing.T) {
+	t.Run("stop_on_cache_miss", func(t *testing.T) {
+		bMock := &benchmarkMock{timerOn: true}
+		e := newTestEnv(bMock)
+
+		var timerOnInFixture bool
+		fixture := func() int {
+			return e.CacheResult(func() (*Result, error) {
+				timerOnInFixture = bMock.timerOn
+				return NewResult(1), nil
+			}).(int)
+		}
+
+		fixture()
+		requireFalse(t, timerOnInFixture)
+		requireTrue(t, bMock.timerOn)
+		requireEquals(t, 1, bMock.stopCalls)
+		requireEquals(t, 1, bMock.startCalls)
+
+		// cache hit
+		fixture()
+		requireEquals(t, 1, bMock.stopCalls)
+		requireEquals(t, 1, bMock.startCalls)
+	})
+
+	t.Run("nested", func(t *testing.T) {
+		bMock := &benchmarkMock{timerOn: true}
+		e := newTestEnv(bMock)
+
+		var timerOnAfterInner bool
+		inner := func() int {
+			return e.CacheResult(func() (*Result, error) {
+				return NewResult(1), nil
+			}).(int)
+		}
+		outer := func() int {
+			return e.CacheResult(func() (*Result, error) {
+				res := inner() + 1
+				timerOnAfterInner = bMock.timerOn
+				return NewResult(res), nil
+			}).(int)
+		}
+
+		requireEquals(t, 2, outer())
+		requireFalse(t, timerOnAfterInner)
+		requireTrue(t, bMock.timerOn)
+		requireEquals(t, 1, bMock.stopCalls)
+		requireEquals(t, 1, bMock.startCalls)
+	})
+
+	t.Run("keep_timer", func(t *testing.T) {
+		bMock := &benchmarkMock{timerOn: true}
+		e := newTestEnv(bMock)
+
+		e.CacheResult(func() (*Result, error) {
+			return NewResult(1), nil
+		}, CacheOptions{KeepBenchmarkTimer: true})
+		requireEquals(t, 0, bMock.stopCalls)
+		requireEquals(t, 0, bMock.startCalls)
+	})
+
+	t.Run("testing_b", func(t *testing.T) {
+		benchTime := flag.Lookup("test.benchtime")
+		oldBenchTime := benchTime.Value.String()
+		noError(t, benchTime.Value.Set("100x"))
+		defer func() {
+			_ = benchTime.Value.Set(oldBenchTime)
+		}()
+
+		calls := 0
+		cleanups := 0
+		benchmarkCalls := 0
+		testing.Benchmark(func(b *testing.B) {
+			benchmarkCalls++
+			e := New(b)
+			for i := 0; i < b.N; i++ {
+				e.CacheResult(func() (*Result, error) {
+					calls++
+					return NewResultWithCleanup(calls, func() {
+						cleanups++
+					}), nil
+				})
+			}
+		})
+
+		// ScopeTest fixture created once per call of benchmark function
+		requireEquals(t, benchmarkCalls, calls)
+		requireEquals(t, benchmarkCalls, cleanups)
+	})
+}
@@ -1,27 +1,133 @@
 package fixenv
 
 import (
+	"container/list"
 	"errors"
+	"fmt"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type cache struct {
 	m        sync.RWMutex
 	store    map[cacheKey]cacheVal
 	setLocks map[cacheKey]*sync.Once
+
+	// lru is recently used keys of fixtures with limit of entries, by fixture key
+	lru map[cacheKey]*lruGroup
+	// lruKeys is fixture key of cache key
+	lruKeys map[cacheKey]cacheKey
+}
+
+// lruGroup is cache keys of one fixture, most recently used first
+type lruGroup struct {
+	list  *list.List
+	items map[cacheKey]*list.Element
 }
 
-type cacheKey string
+// cacheItem is key and value of cache
+type cacheItem struct {
+	key cacheKey
+	val cacheVal
+}
 
 type cacheVal struct {
-	res *Result
-	err error
+	res  *Result
+	err  error
+	stat *cacheStat
+}
+
+// cacheStat is mutable statistic of cached value
+type cacheStat struct {
+	created time.Time
+
+	// hits is count of returns value from cache
+	hits int64
+
+	// validated is unix nano time of last validation or creation of the value
+	validated int64
+
+	// digest of value for check mutations, calculated on first read of the value
+	digestM sync.Mutex
+	digest  string
+}
+
+func newCacheStat() *cacheStat {
+	now := time.Now()
+	return &cacheStat{
+		created:   now,
+		validated: now.UnixNano(),
+	}
+}
+
+// updateDigest calculate digest of value and compare it with previous digest.
+// If init is true - it only set first digest of the value.
+func (s *cacheStat) updateDigest(v interface{}, init bool) (changed bool, err error) {
+	s.digestM.Lock()
+	defer s.digestM.Unlock()
+
+	if init && s.digest != "" {
+		return false, nil
+	}
+
+	digest, err := valueDigest(v)
+	if err != nil {
+		return false, err
+	}
+	changed = s.digest != "" && s.digest != digest
+	s.digest = digest
+	return changed, nil
+}
+
+// hit register return value from cache and return error if the value expired
+func (v cacheVal) hit() error {
+	if v.stat == nil {
+		return nil
+	}
+
+	hits := atomic.AddInt64(&v.stat.hits, 1)
+	if v.res == nil {
+		return nil
+	}
+	if v.res.MaxAge > 0 {
+		if age := time.Since(v.stat.created); age >= v.res.MaxAge {
+			return fmt.Errorf("value expired, age: %v, max age: %v", age, v.res.MaxAge)
+		}
+	}
+	if v.res.MaxHits > 0 && hits > int64(v.res.MaxHits) {
+		return fmt.Errorf("value expired, max hits: %v", v.res.MaxHits)
+	}
+	return nil
+}
+
+// validate call validator of cached value, if it has validator and validate interval passed
+func (v cacheVal) validate() error {
+	if v.res == nil || v.res.Validate == nil || v.stat == nil {
+		return nil
+	}
+
+	interval := int64(v.res.ValidateInterval)
+	if interval > 0 {
+		now := time.Now().UnixNano()
+		last := atomic.LoadInt64(&v.stat.validated)
+		if now-last < interval {
+			return nil
+		}
+		if !atomic.CompareAndSwapInt64(&v.stat.validated, last, now) {
+			// validated by other goroutine
+			return nil
+		}
+	}
+	return v.res.Validate()
 }
 
 func newCache() *cache {
 	return &cache{
 		store:    make(map[cacheKey]cacheVal),
 		setLocks: make(map[cacheKey]*sync.Once),
+		lru:      make(map[cacheKey]*lruGroup),
+		lruKeys:  make(map[cacheKey]cacheKey),
 	}
 }
 
@@ -29,25 +135,98 @@ func newCache() *cache {
 // it has guarantee about only one f will execute same time for the key.
 // but many f may execute simultaneously for different keys
 func (c *cache) GetOrSet(key cacheKey, f FixtureFunction) (*Result, error) {
-	res, ok := c.get(key)
+	val, _ := c.getOrSet(key, f)
+	return val.res, val.err
+}
+
+// getOrSet is same as GetOrSet, but return cached value with statistic
+// and created flag, if f called by the call.
+func (c *cache) getOrSet(key cacheKey, f FixtureFunction) (val cacheVal, created bool) {
+	val, ok := c.get(key)
 	if ok {
-		return res.res, res.err
+		return val, false
 	}
 
-	c.setOnce(key, f)
+	created = c.setOnce(key, f)
+
+	val, _ = c.get(key)
+	return val, created
+}
+
+// delete remove value from cache if it is same value as val.
+// Return true if the value deleted.
+func (c *cache) delete(key cacheKey, val cacheVal) bool {
+	c.m.Lock()
+	defer c.m.Unlock()
 
-	res, _ = c.get(key)
-	return res.res, res.err
+	if current, ok := c.store[key]; !ok || current.stat != val.stat {
+		return false
+	}
+	c.deleteKey(key)
+	return true
 }
 
-func (c *cache) DeleteKeys(keys ...cacheKey) {
+// touch mark key as most recently used key of fixture and evict least recently used keys
+// of the fixture over max entries.
+func (c *cache) touch(fixtureKey, key cacheKey, maxEntries int) []cacheItem {
 	c.m.Lock()
 	defer c.m.Unlock()
 
-	for _, key := range keys {
-		delete(c.store, key)
-		delete(c.setLocks, key)
+	if _, ok := c.store[key]; !ok {
+		// removed already
+		return nil
+	}
+
+	group := c.lru[fixtureKey]
+	if group == nil {
+		group = &lruGroup{list: list.New(), items: make(map[cacheKey]*list.Element)}
+		c.lru[fixtureKey] = group
+	}
+	if item, ok := group.items[key]; ok {
+		group.list.MoveToFront(item)
+	} else {
+		group.items[key] = group.list.PushFront(key)
+		c.lruKeys[key] = fixtureKey
+	}
+
+	var evicted []cacheItem
+	for group.list.Len() > maxEntries {
+		evictedKey := group.list.Back().Value.(cacheKey)
+		evicted = append(evicted, cacheItem{key: evictedKey, val: c.store[evictedKey]})
+		c.deleteKey(evictedKey)
+	}
+	return evicted
+}
+
+// deleteKey remove key from cache, must be called under lock
+func (c *cache) deleteKey(key cacheKey) {
+	delete(c.store, key)
+	delete(c.setLocks, key)
+
+	fixtureKey, ok := c.lruKeys[key]
+	if !ok {
+		return
+	}
+	delete(c.lruKeys, key)
+
+	group := c.lru[fixtureKey]
+	group.list.Remove(group.items[key])
+	delete(group.items, key)
+	if group.list.Len() == 0 {
+		delete(c.lru, fixtureKey)
+	}
+}
+
+// items return all values of the cache
+func (c *cache) items() []cacheItem {
+	c.m.RLock()
+	defer c.m.RUnlock()
+
+	res := make([]cacheItem, 0, len(c.store))
+	for key, val := range c.store {
+		res = append(res, cacheItem{key: key, val: val})
 	}
+	return res
 }
 
 func (c *cache) get(key cacheKey) (cacheVal, bool) {
@@ -57,7 +236,7 @@ func (c *cache) get(key cacheKey) (cacheVal, bool) {
 	return val, ok
 }
 
-func (c *cache) setOnce(key cacheKey, f FixtureFunction) {
+func (c *cache) setOnce(key cacheKey, f FixtureFunction) (called bool) {
 	c.m.Lock()
 	setOnce := c.setLocks[key]
 	if setOnce == nil {
@@ -67,6 +246,7 @@ func (c *cache) setOnce(key cacheKey, f FixtureFunction) {
 	c.m.Unlock()
 
 	setOnce.Do(func() {
+		called = true
 		var err = errors.New("unexpected exit from function")
 		var res *Result
 
@@ -74,10 +254,11 @@ func (c *cache) setOnce(key cacheKey, f FixtureFunction) {
 		// for example by panic or GoExit
 		defer func() {
 			c.m.Lock()
-			c.store[key] = cacheVal{res: res, err: err}
+			c.store[key] = cacheVal{res: res, err: err, stat: newCacheStat()}
 			c.m.Unlock()
 		}()
 
 		res, err = f()
 	})
+	return called
 }
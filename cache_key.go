@@ -0,0 +1,419 @@
+package fixenv
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// externalCallerLevel is count of frames from runtime.Callers to external caller of env,
+// see makeCacheKey
+const externalCallerLevel = 5
+
+// cacheKey is identity of cached value: fixture, scope and params of the fixture call.
+// It is comparable without allocations for params of basic types and plain structs,
+// other params are serialized to json.
+type cacheKey struct {
+	fixture   *fixtureID
+	scope     CacheScope
+	scopeName string
+	params    interface{}
+}
+
+// fixtureID is identity of fixture function, one pointer per function
+type fixtureID struct {
+	function string
+	file     string
+}
+
+// CacheKeyer is implemented by values, which used as CacheOptions.CacheKey and can't be
+// compared or serialized to json as is: structs with unexported fields, handles, connections, etc.
+// CacheKey must return same key for equal values and different keys for different values.
+// The key compared with other keys of same type only. If the key is pointer - the pointer is identity
+// of the value, other keys compared as CacheOptions.CacheKey.
+type CacheKeyer interface {
+	CacheKey() interface{}
+}
+
+// jsonKeyParams is json representation of params, which can't be used as map key directly
+type jsonKeyParams struct {
+	typ  reflect.Type
+	data string
+}
+
+// keyerKeyParams is key, returned by CacheKeyer or registered cache key function
+type keyerKeyParams struct {
+	typ reflect.Type
+	key interface{}
+}
+
+// typedKeyParams is implemented by keys of CacheResultKey, they compared by go equality
+// and used in cache key as is
+type typedKeyParams interface {
+	typedKeyValue() interface{}
+}
+
+// callersKey is raw stack of CacheResult call, it used for find fixture without decode frames
+type callersKey struct {
+	pcs      [externalCallerLevel]uintptr
+	testCall bool
+}
+
+var (
+	fixtureIDsMutex     sync.RWMutex
+	fixtureIDsByCallers = map[callersKey]*fixtureID{}
+	fixtureIDsByName    = map[fixtureID]*fixtureID{}
+
+	// plainKeyTypes is cache of isPlainKeyType results: reflect.Type -> bool
+	plainKeyTypes sync.Map
+
+	cacheKeyFuncsMutex sync.RWMutex
+	cacheKeyFuncs      = map[reflect.Type]reflect.Value{}
+
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// RegisterCacheKeyFunc register function, which return cache key for values of type T,
+// when the values used as CacheOptions.CacheKey. f must have signature func(T) K,
+// result of f used same as result of CacheKeyer.CacheKey. It has priority over CacheKeyer interface.
+// Use it for types from other packages, which can't be serialized to json.
+// RegisterCacheKeyFunc panics if f has other signature.
+func RegisterCacheKeyFunc(f interface{}) {
+	fVal := reflect.ValueOf(f)
+	fType := fVal.Type()
+	if fType.Kind() != reflect.Func || fType.NumIn() != 1 || fType.NumOut() != 1 {
+		panic(fmt.Errorf("fixenv: cache key function must have signature func(T) K, got: %v", fType))
+	}
+
+	cacheKeyFuncsMutex.Lock()
+	defer cacheKeyFuncsMutex.Unlock()
+
+	cacheKeyFuncs[fType.In(0)] = fVal
+}
+
+func getCacheKeyFunc(t reflect.Type) (reflect.Value, bool) {
+	cacheKeyFuncsMutex.RLock()
+	defer cacheKeyFuncsMutex.RUnlock()
+
+	f, ok := cacheKeyFuncs[t]
+	return f, ok
+}
+
+// String return human readable description of json key
+func (p jsonKeyParams) String() string {
+	return p.data
+}
+
+// String return human readable description of key from CacheKeyer
+func (p keyerKeyParams) String() string {
+	return fmt.Sprintf("%v(%v)", p.typ, p.key)
+}
+
+// String return human readable description of fixture call
+func (k cacheKey) String() string {
+	var parts []string
+	if k.fixture != nil {
+		parts = append(parts, fmt.Sprintf("%v (%v)", k.fixture.function, k.fixture.file))
+	}
+	if k.params != nil {
+		parts = append(parts, fmt.Sprintf("with params %v", k.params))
+	}
+	return strings.Join(parts, " ")
+}
+
+// fixtureKey return key of fixture in the scope: cache key without params
+func (k cacheKey) fixtureKey() cacheKey {
+	k.params = nil
+	return k
+}
+
+// makeCacheKey generate cache key
+// must be called from first level of env functions - for detect external caller
+func makeCacheKey(scopeName string, options CacheOptions, testCall bool) (cacheKey, error) {
+	callers := callersKey{testCall: testCall}
+	if externalCallerLevel != runtime.Callers(options.additionlSkipExternalCalls, callers.pcs[:]) {
+		return makeCacheKeyFromFrame(options.CacheKey, options.Scope, runtime.Frame{}, scopeName, testCall)
+	}
+
+	fixture, err := fixtureIDByCallers(callers)
+	if err != nil {
+		return cacheKey{}, err
+	}
+	return newCacheKey(fixture, options.Scope, scopeName, options.CacheKey)
+}
+
+func makeCacheKeyFromFrame(params interface{}, scope CacheScope, f runtime.Frame, scopeName string, testCall bool) (cacheKey, error) {
+	fixture, err := internFixtureID(f, testCall)
+	if err != nil {
+		return cacheKey{}, err
+	}
+	return newCacheKey(fixture, scope, scopeName, params)
+}
+
+func newCacheKey(fixture *fixtureID, scope CacheScope, scopeName string, params interface{}) (cacheKey, error) {
+	keyParams, err := makeKeyParams(params)
+	if err != nil {
+		return cacheKey{}, err
+	}
+	return cacheKey{
+		fixture:   fixture,
+		scope:     scope,
+		scopeName: scopeName,
+		params:    keyParams,
+	}, nil
+}
+
+// fixtureIDByCallers return fixture of external caller, decoded frames cached by raw stack
+func fixtureIDByCallers(callers callersKey) (*fixtureID, error) {
+	fixtureIDsMutex.RLock()
+	fixture, ok := fixtureIDsByCallers[callers]
+	fixtureIDsMutex.RUnlock()
+	if ok {
+		return fixture, nil
+	}
+
+	pcs := callers.pcs
+	frames := runtime.CallersFrames(pcs[:])
+	frames.Next()                      // callers
+	frames.Next()                      // the function
+	frames.Next()                      // caller of the function (env private function)
+	frames.Next()                      // caller of private function (env public function)
+	extCallerFrame, _ := frames.Next() // external caller
+
+	fixture, err := internFixtureID(extCallerFrame, callers.testCall)
+	if err != nil {
+		return nil, err
+	}
+
+	fixtureIDsMutex.Lock()
+	fixtureIDsByCallers[callers] = fixture
+	fixtureIDsMutex.Unlock()
+	return fixture, nil
+}
+
+// internFixtureID return same pointer for every call of same fixture function
+func internFixtureID(f runtime.Frame, testCall bool) (*fixtureID, error) {
+	switch {
+	case f.Function == "":
+		return nil, errors.New("failed to detect caller func name")
+	case f.File == "":
+		return nil, errors.New("failed to detect caller func file")
+	default:
+		// pass
+	}
+
+	id := fixtureID{function: f.Function, file: f.File}
+	if testCall {
+		id.file = ".../" + filepath.Base(id.file)
+	}
+
+	fixtureIDsMutex.Lock()
+	defer fixtureIDsMutex.Unlock()
+
+	if fixture, ok := fixtureIDsByName[id]; ok {
+		return fixture, nil
+	}
+	fixture := &id
+	fixtureIDsByName[id] = fixture
+	return fixture, nil
+}
+
+// makeKeyParams return comparable representation of params.
+// Basic types and plain structs used as is, values with registered cache key function
+// or CacheKeyer replaced by its keys, other values serialized to json.
+func makeKeyParams(params interface{}) (interface{}, error) {
+	switch params.(type) {
+	case nil, string, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return params, nil
+	}
+
+	if _, ok := params.(typedKeyParams); ok {
+		return params, nil
+	}
+
+	paramsType := reflect.TypeOf(params)
+	if f, ok := getCacheKeyFunc(paramsType); ok {
+		return makeKeyerParams(paramsType, f.Call([]reflect.Value{reflect.ValueOf(params)})[0].Interface())
+	}
+	if keyer, ok := params.(CacheKeyer); ok && !isNilValue(reflect.ValueOf(params)) {
+		return makeKeyerParams(paramsType, keyer.CacheKey())
+	}
+
+	return makeValueKeyParams(params)
+}
+
+func makeKeyerParams(t reflect.Type, key interface{}) (interface{}, error) {
+	res := keyerKeyParams{typ: t}
+	switch reflect.ValueOf(key).Kind() {
+	case reflect.Invalid:
+		// nil key
+	case reflect.Ptr, reflect.Chan, reflect.UnsafePointer:
+		res.key = key
+	default:
+		var err error
+		res.key, err = makeValueKeyParams(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to make cache key of %v: %w", t, err)
+		}
+	}
+	return res, nil
+}
+
+// makeValueKeyParams return params as is for plain types and serialize other values to json
+func makeValueKeyParams(params interface{}) (interface{}, error) {
+	paramsType := reflect.TypeOf(params)
+	if paramsType == nil || isPlainKeyType(paramsType) {
+		return params, nil
+	}
+
+	if err := checkKeyEncoding(reflect.ValueOf(params), map[uintptr]bool{}); err != nil {
+		return nil, err
+	}
+
+	keyBytes, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize params to json: %v", err)
+	}
+	return jsonKeyParams{typ: paramsType, data: string(keyBytes)}, nil
+}
+
+// checkKeyEncoding return error if json representation of v lost part of the value,
+// then different values will have same cache key. For example struct with unexported fields only
+// serialized as {}.
+func checkKeyEncoding(v reflect.Value, visited map[uintptr]bool) error {
+	if !v.IsValid() {
+		return nil
+	}
+
+	t := v.Type()
+	if t.Implements(jsonMarshalerType) || t.Implements(textMarshalerType) ||
+		(v.CanAddr() && (reflect.PtrTo(t).Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType))) {
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() || visited[v.Pointer()] {
+			return nil
+		}
+		visited[v.Pointer()] = true
+		return checkKeyEncoding(v.Elem(), visited)
+	case reflect.Interface:
+		return checkKeyEncoding(v.Elem(), visited)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := checkKeyEncoding(v.Index(i), visited); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			if err := checkKeyEncoding(iter.Value(), visited); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.Tag.Get("json") == "-" {
+				continue
+			}
+			if field.PkgPath != "" && !field.Anonymous {
+				return fmt.Errorf("ambiguous cache key: type %v has unexported field %v, which is not serialized to json, "+
+					"implement fixenv.CacheKeyer or register cache key function", t, field.Name)
+			}
+			if err := checkKeyEncoding(v.Field(i), visited); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer, reflect.Complex64, reflect.Complex128:
+		return fmt.Errorf("can't use value of type %v as cache key, implement fixenv.CacheKeyer or register cache key function", t)
+	default:
+		return nil
+	}
+}
+
+// checkComparableKey return error if key can't be compared by go equality.
+// Interfaces satisfy comparable constraint since go 1.20, but dynamic value of interface may be not comparable,
+// for example CacheResultKey[any] with []int key, then map with the key panics.
+func checkComparableKey(key interface{}) error {
+	return checkComparableValue(reflect.ValueOf(key))
+}
+
+func checkComparableValue(v reflect.Value) error {
+	if !v.IsValid() {
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Interface:
+		return checkComparableValue(v.Elem())
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if err := checkComparableValue(v.Field(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := checkComparableValue(v.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		if !v.Type().Comparable() {
+			return fmt.Errorf("cache key of type %v is not comparable", v.Type())
+		}
+		return nil
+	}
+}
+
+// isPlainKeyType return true if values of the type are equal exactly when they json representations are equal.
+// It is strings, bools, integers and arrays and structs of them without custom marshalers.
+func isPlainKeyType(t reflect.Type) bool {
+	if res, ok := plainKeyTypes.Load(t); ok {
+		return res.(bool)
+	}
+
+	res := checkPlainKeyType(t)
+	plainKeyTypes.Store(t, res)
+	return res
+}
+
+func checkPlainKeyType(t reflect.Type) bool {
+	if t.Implements(jsonMarshalerType) || t.Implements(textMarshalerType) ||
+		reflect.PtrTo(t).Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType) {
+		return false
+	}
+
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	case reflect.Array:
+		return checkPlainKeyType(t.Elem())
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if !checkPlainKeyType(t.Field(i).Type) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,9 @@
+//go:build go1.18
+// +build go1.18
+
+package fixenv
+
+// RegisterCacheKey is typed version of RegisterCacheKeyFunc
+func RegisterCacheKey[T any, K any](f func(T) K) {
+	RegisterCacheKeyFunc(f)
+}
@@ -0,0 +1,29 @@
+//go:build go1.18
+// +build go1.18
+
+package fixenv
+
+import "testing"
+
+type cacheKeyTestGeneric struct {
+	name string
+}
+
+func TestRegisterCacheKey(t *testing.T) {
+	RegisterCacheKey(func(v cacheKeyTestGeneric) string {
+		return v.name
+	})
+
+	e := newTestEnv(t)
+	calls := 0
+	fixture := func(key cacheKeyTestGeneric) int {
+		return CacheResult(e, func() (*GenericResult[int], error) {
+			calls++
+			return NewGenericResult(calls), nil
+		}, CacheOptions{CacheKey: key})
+	}
+
+	requireEquals(t, 1, fixture(cacheKeyTestGeneric{name: "a"}))
+	requireEquals(t, 1, fixture(cacheKeyTestGeneric{name: "a"}))
+	requireEquals(t, 2, fixture(cacheKeyTestGeneric{name: "b"}))
+}
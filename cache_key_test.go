@@ -0,0 +1,273 @@
+package fixenv
+
+import (
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/rekby/fixenv/internal"
+)
+
+type cacheKeyTestPlain struct {
+	Name string
+	ID   int
+	Arr  [2]uint8
+}
+
+type cacheKeyTestPointer struct {
+	Name *string
+}
+
+type cacheKeyTestMarshaler struct {
+	Name string
+}
+
+func (m cacheKeyTestMarshaler) MarshalText() ([]byte, error) {
+	return []byte("same"), nil
+}
+
+type cacheKeyTestUnexported struct {
+	name  string
+	items []string
+}
+
+type cacheKeyTestKeyer struct {
+	id    int
+	items []string
+}
+
+func (k cacheKeyTestKeyer) CacheKey() interface{} {
+	return k.id
+}
+
+type cacheKeyTestHandle struct {
+	conn []int
+}
+
+func (h *cacheKeyTestHandle) CacheKey() interface{} {
+	return h
+}
+
+type cacheKeyTestRegistered struct {
+	name  string
+	items []string
+}
+
+func jsonKey(params interface{}, data string) jsonKeyParams {
+	return jsonKeyParams{typ: reflect.TypeOf(params), data: data}
+}
+
+func TestCacheKey_String(t *testing.T) {
+	key, err := makeCacheKeyFromFrame(123, ScopeTest, runtime.Frame{
+		Function: "pkg.Fixture",
+		File:     "/a/b.go",
+	}, "mock", false)
+	noError(t, err)
+	requireEquals(t, "pkg.Fixture (/a/b.go) with params 123", key.String())
+	requireEquals(t, "with params asd", testCacheKey("asd").String())
+}
+
+func TestCacheKey_FixtureKey(t *testing.T) {
+	key := cacheKey{fixture: &fixtureID{function: "f"}, scope: ScopePackage, scopeName: "s", params: 1}
+	requireEquals(t, cacheKey{fixture: key.fixture, scope: ScopePackage, scopeName: "s"}, key.fixtureKey())
+}
+
+func TestInternFixtureID(t *testing.T) {
+	frame := runtime.Frame{Function: "f", File: "/a/b.go"}
+	id1, err := internFixtureID(frame, false)
+	noError(t, err)
+	id2, err := internFixtureID(frame, false)
+	noError(t, err)
+	requireTrue(t, id1 == id2)
+
+	id3, err := internFixtureID(frame, true)
+	noError(t, err)
+	requireTrue(t, id1 != id3)
+}
+
+func TestMakeKeyParams(t *testing.T) {
+	name := "name"
+	table := []struct {
+		name   string
+		params interface{}
+		result interface{}
+	}{
+		{name: "nil", params: nil, result: nil},
+		{name: "string", params: "asd", result: "asd"},
+		{name: "int", params: 1, result: 1},
+		{name: "uint64", params: uint64(1), result: uint64(1)},
+		{name: "plain_struct", params: cacheKeyTestPlain{Name: "a"}, result: cacheKeyTestPlain{Name: "a"}},
+		{name: "float", params: 1.5, result: jsonKey(1.5, "1.5")},
+		{name: "slice", params: []int{1, 2}, result: jsonKey([]int{1, 2}, "[1,2]")},
+		{name: "pointer", params: cacheKeyTestPointer{Name: &name}, result: jsonKey(cacheKeyTestPointer{Name: &name}, `{"Name":"name"}`)},
+		{name: "marshaler", params: cacheKeyTestMarshaler{Name: "a"}, result: jsonKey(cacheKeyTestMarshaler{Name: "a"}, `"same"`)},
+		{name: "time", params: time.Unix(0, 0).UTC(), result: jsonKey(time.Unix(0, 0).UTC(), `"1970-01-01T00:00:00Z"`)},
+	}
+
+	for _, test := range table {
+		t.Run(test.name, func(t *testing.T) {
+			res, err := makeKeyParams(test.params)
+			noError(t, err)
+			requireEquals(t, test.result, res)
+		})
+	}
+
+	_, err := makeKeyParams(func() {})
+	isError(t, err)
+}
+
+func TestMakeKeyParams_Keyer(t *testing.T) {
+	t.Run("value", func(t *testing.T) {
+		k1, err := makeKeyParams(cacheKeyTestKeyer{id: 1, items: []string{"a"}})
+		noError(t, err)
+		k2, err := makeKeyParams(cacheKeyTestKeyer{id: 1, items: []string{"b"}})
+		noError(t, err)
+		k3, err := makeKeyParams(cacheKeyTestKeyer{id: 2})
+		noError(t, err)
+		requireEquals(t, k1, k2)
+		requireNotEquals(t, k1, k3)
+
+		// same key from other type is other key
+		requireNotEquals(t, interface{}(1), k1)
+	})
+
+	t.Run("pointer_identity", func(t *testing.T) {
+		h1 := &cacheKeyTestHandle{}
+		h2 := &cacheKeyTestHandle{}
+		k1, err := makeKeyParams(h1)
+		noError(t, err)
+		k1Again, err := makeKeyParams(h1)
+		noError(t, err)
+		k2, err := makeKeyParams(h2)
+		noError(t, err)
+		requireTrue(t, k1 == k1Again)
+		requireTrue(t, k1 != k2)
+	})
+
+	t.Run("registered", func(t *testing.T) {
+		RegisterCacheKeyFunc(func(v cacheKeyTestRegistered) []string {
+			return append([]string{v.name}, v.items...)
+		})
+		k1, err := makeKeyParams(cacheKeyTestRegistered{name: "a", items: []string{"b"}})
+		noError(t, err)
+		k2, err := makeKeyParams(cacheKeyTestRegistered{name: "a", items: []string{"c"}})
+		noError(t, err)
+		requireNotEquals(t, k1, k2)
+
+		requirePanic(t, func() {
+			RegisterCacheKeyFunc(func(v int) (string, error) { return "", nil })
+		})
+	})
+
+	t.Run("bad_key", func(t *testing.T) {
+		RegisterCacheKeyFunc(func(v cacheKeyTestRegistered) interface{} {
+			return func() {}
+		})
+		defer RegisterCacheKeyFunc(func(v cacheKeyTestRegistered) string { return v.name })
+
+		_, err := makeKeyParams(cacheKeyTestRegistered{})
+		isError(t, err)
+	})
+}
+
+func TestMakeKeyParams_Ambiguous(t *testing.T) {
+	for _, params := range []interface{}{
+		cacheKeyTestUnexported{name: "a"},
+		&cacheKeyTestUnexported{name: "a"},
+		[]cacheKeyTestUnexported{{name: "a"}},
+		map[string]interface{}{"a": cacheKeyTestUnexported{name: "a"}},
+		struct{ Ch chan int }{Ch: make(chan int)},
+	} {
+		_, err := makeKeyParams(params)
+		isError(t, err)
+	}
+
+	type ignored struct {
+		Name string
+		Skip func() `json:"-"`
+	}
+	res, err := makeKeyParams(ignored{Name: "a"})
+	noError(t, err)
+	requireEquals(t, jsonKey(ignored{}, `{"Name":"a"}`), res)
+}
+
+func TestCheckComparableKey(t *testing.T) {
+	type withInterface struct {
+		Key interface{}
+	}
+
+	for _, key := range []interface{}{
+		nil,
+		1,
+		"a",
+		&cacheKeyTestPlain{},
+		cacheKeyTestPlain{},
+		withInterface{Key: 1},
+		[2]interface{}{1, "a"},
+	} {
+		noError(t, checkComparableKey(key))
+	}
+
+	for _, key := range []interface{}{
+		[]int{1},
+		map[string]int{},
+		func() {},
+		withInterface{Key: []int{1}},
+		[1]interface{}{[]int{1}},
+	} {
+		isError(t, checkComparableKey(key))
+	}
+}
+
+func BenchmarkMakeCacheKey(b *testing.B) {
+	table := []struct {
+		name   string
+		params interface{}
+	}{
+		{name: "nil", params: nil},
+		{name: "string", params: "tenant"},
+		{name: "int", params: 123},
+		{name: "plain_struct", params: cacheKeyTestPlain{Name: "tenant", ID: 1}},
+		{name: "json", params: map[string]int{"tenant": 1}},
+	}
+
+	for _, test := range table {
+		b.Run(test.name, func(b *testing.B) {
+			b.ReportAllocs()
+			options := CacheOptions{CacheKey: test.params}
+			for i := 0; i < b.N; i++ {
+				benchmarkMakeCacheKeyPublic(options)
+			}
+		})
+	}
+}
+
+// benchmarkMakeCacheKeyPublic and benchmarkMakeCacheKeyPrivate emulate call stack of env
+func benchmarkMakeCacheKeyPublic(options CacheOptions) {
+	benchmarkMakeCacheKeyPrivate(options)
+}
+
+//go:noinline
+func benchmarkMakeCacheKeyPrivate(options CacheOptions) {
+	if _, err := makeCacheKey("scope", options, false); err != nil {
+		panic(err)
+	}
+}
+
+func BenchmarkEnv_CacheResultHit(b *testing.B) {
+	tMock := &internal.TestMock{TestName: "mock"}
+	defer tMock.CallCleanup()
+	e := newTestEnv(tMock)
+
+	f := func() (*Result, error) {
+		return NewResult(1), nil
+	}
+	options := CacheOptions{CacheKey: "tenant"}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		e.CacheResult(f, options)
+	}
+}
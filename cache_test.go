@@ -1,6 +1,7 @@
 package fixenv
 
 import (
+	"errors"
 	"math/rand"
 	"runtime"
 	"strconv"
@@ -8,16 +9,18 @@ import (
 	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/rekby/fixenv/internal"
 )
 
 const waitTime = time.Second / 10
 
-func TestCache_DeleteKeys(t *testing.T) {
+func TestCache_DeleteKey(t *testing.T) {
 	t.Run("simple", func(t *testing.T) {
 		c := newCache()
-		k1 := cacheKey("k1")
-		k2 := cacheKey("k2")
-		k3 := cacheKey("k3")
+		k1 := testCacheKey("k1")
+		k2 := testCacheKey("k2")
+		k3 := testCacheKey("k3")
 		val1 := "test1"
 		valFunc := func() (*Result, error) {
 			return NewResult(val1), nil
@@ -27,7 +30,10 @@ func TestCache_DeleteKeys(t *testing.T) {
 		c.setOnce(k2, valFunc)
 		c.setOnce(k3, valFunc)
 
-		c.DeleteKeys(k1, k2)
+		c.m.Lock()
+		c.deleteKey(k1)
+		c.deleteKey(k2)
+		c.m.Unlock()
 		_, ok := c.get(k1)
 		requireFalse(t, ok)
 		_, ok = c.get(k2)
@@ -47,15 +53,16 @@ func TestCache_DeleteKeys(t *testing.T) {
 
 	t.Run("mutex", func(t *testing.T) {
 		c := newCache()
-		c.setOnce("asd", func() (res *Result, err error) {
+		c.setOnce(testCacheKey("asd"), func() (res *Result, err error) {
 			return NewResult(nil), nil
 		})
+		val, _ := c.get(testCacheKey("asd"))
 
 		c.m.RLock()
 		var wg sync.WaitGroup
 		wg.Add(1)
 		go func() {
-			c.DeleteKeys("asd")
+			c.delete(testCacheKey("asd"), val)
 			wg.Done()
 		}()
 
@@ -74,30 +81,30 @@ func TestCache_Get(t *testing.T) {
 	t.Run("simple", func(t *testing.T) {
 		c := newCache()
 
-		_, ok := c.get("qwe")
+		_, ok := c.get(testCacheKey("qwe"))
 		requireFalse(t, ok)
 
-		c.store["asd"] = cacheVal{res: NewResult("val")}
+		c.store[testCacheKey("asd")] = cacheVal{res: NewResult("val")}
 
-		res, ok := c.get("asd")
+		res, ok := c.get(testCacheKey("asd"))
 		requireTrue(t, ok)
 		requireEquals(t, cacheVal{res: NewResult("val")}, res)
 	})
 
 	t.Run("read_mutex", func(t *testing.T) {
 		c := newCache()
-		c.setOnce("asd", func() (res *Result, err error) {
+		c.setOnce(testCacheKey("asd"), func() (res *Result, err error) {
 			return NewResult(nil), nil
 		})
 		c.m.RLock()
-		_, ok := c.get("asd")
+		_, ok := c.get(testCacheKey("asd"))
 		c.m.RUnlock()
 		requireTrue(t, ok)
 	})
 
 	t.Run("write_mutex", func(t *testing.T) {
 		c := newCache()
-		c.setOnce("asd", func() (res *Result, err error) {
+		c.setOnce(testCacheKey("asd"), func() (res *Result, err error) {
 			return NewResult(nil), nil
 		})
 		c.m.Lock()
@@ -105,7 +112,7 @@ func TestCache_Get(t *testing.T) {
 		var wg sync.WaitGroup
 		wg.Add(1)
 		go func() {
-			_, ok = c.get("asd")
+			_, ok = c.get(testCacheKey("asd"))
 			wg.Done()
 		}()
 
@@ -124,7 +131,7 @@ func TestCache_SetOnce(t *testing.T) {
 	t.Run("save_new_key", func(t *testing.T) {
 		c := newCache()
 		cnt := 0
-		key1 := cacheKey("1")
+		key1 := testCacheKey("1")
 		c.setOnce(key1, func() (res *Result, err error) {
 			cnt++
 			return NewResult(1), nil
@@ -144,8 +151,8 @@ func TestCache_SetOnce(t *testing.T) {
 
 	t.Run("second_set_val", func(t *testing.T) {
 		c := newCache()
-		key1 := cacheKey("1")
-		key2 := cacheKey("2")
+		key1 := testCacheKey("1")
+		key2 := testCacheKey("2")
 		cnt := 0
 		c.setOnce(key1, func() (res *Result, err error) {
 			cnt++
@@ -165,7 +172,7 @@ func TestCache_SetOnce(t *testing.T) {
 	// exit without return value
 	t.Run("exit_without_return", func(t *testing.T) {
 		c := newCache()
-		key := cacheKey("3")
+		key := testCacheKey("3")
 		var wg sync.WaitGroup
 		wg.Add(1)
 		go func() {
@@ -183,7 +190,7 @@ func TestCache_SetOnce(t *testing.T) {
 
 	t.Run("second_func_same_key_wait", func(t *testing.T) {
 		c := newCache()
-		key := cacheKey("1")
+		key := testCacheKey("1")
 
 		var firstMuStarted = make(chan bool)
 
@@ -243,8 +250,8 @@ func TestCache_SetOnce(t *testing.T) {
 
 	t.Run("second_func_other_key_work", func(t *testing.T) {
 		c := newCache()
-		key1 := cacheKey("1")
-		key2 := cacheKey("2")
+		key1 := testCacheKey("1")
+		key2 := testCacheKey("2")
 
 		var firstMuStarted = make(chan bool)
 
@@ -285,6 +292,133 @@ func TestCache_SetOnce(t *testing.T) {
 	})
 }
 
+func TestCache_Delete(t *testing.T) {
+	c := newCache()
+	key := testCacheKey("1")
+	val, created := c.getOrSet(key, func() (*Result, error) {
+		return NewResult(1), nil
+	})
+	requireTrue(t, created)
+
+	_, created = c.getOrSet(key, func() (*Result, error) {
+		return NewResult(2), nil
+	})
+	requireFalse(t, created)
+
+	requireTrue(t, c.delete(key, val))
+	requireFalse(t, c.delete(key, val))
+
+	newVal, created := c.getOrSet(key, func() (*Result, error) {
+		return NewResult(3), nil
+	})
+	requireTrue(t, created)
+	requireEquals(t, 3, newVal.res.Value)
+
+	// old value can't delete new value
+	requireFalse(t, c.delete(key, val))
+}
+
+func TestCacheVal_Validate(t *testing.T) {
+	calls := 0
+	res := NewResult(1)
+	res.Validate = func() error {
+		calls++
+		return nil
+	}
+
+	val := cacheVal{res: res, stat: newCacheStat()}
+	noError(t, val.validate())
+	noError(t, val.validate())
+	requireEquals(t, 2, calls)
+
+	res.ValidateInterval = time.Hour
+	noError(t, val.validate())
+	requireEquals(t, 2, calls)
+
+	atomic.StoreInt64(&val.stat.validated, time.Now().Add(-2*time.Hour).UnixNano())
+	noError(t, val.validate())
+	noError(t, val.validate())
+	requireEquals(t, 3, calls)
+}
+
+func TestCacheVal_Hit(t *testing.T) {
+	t.Run("unlimited", func(t *testing.T) {
+		val := cacheVal{res: NewResult(1), stat: newCacheStat()}
+		for i := 0; i < 10; i++ {
+			noError(t, val.hit())
+		}
+		requireEquals(t, int64(10), val.stat.hits)
+	})
+
+	t.Run("max_hits", func(t *testing.T) {
+		res := NewResult(1)
+		res.MaxHits = 2
+		val := cacheVal{res: res, stat: newCacheStat()}
+		noError(t, val.hit())
+		noError(t, val.hit())
+		requireNotNil(t, val.hit())
+	})
+
+	t.Run("max_age", func(t *testing.T) {
+		res := NewResult(1)
+		res.MaxAge = time.Hour
+		val := cacheVal{res: res, stat: newCacheStat()}
+		noError(t, val.hit())
+
+		val.stat.created = time.Now().Add(-time.Hour)
+		requireNotNil(t, val.hit())
+	})
+
+	t.Run("error_value", func(t *testing.T) {
+		val := cacheVal{err: errors.New("test"), stat: newCacheStat()}
+		noError(t, val.hit())
+	})
+}
+
+func TestCache_Touch(t *testing.T) {
+	c := newCache()
+	set := func(name string) {
+		c.setOnce(testCacheKey(name), func() (*Result, error) {
+			return NewResult(name), nil
+		})
+	}
+	evictedKeys := func(evicted []cacheItem) []cacheKey {
+		var res []cacheKey
+		for _, item := range evicted {
+			res = append(res, item.key)
+		}
+		return res
+	}
+
+	set("1")
+	set("2")
+	set("3")
+	requireEquals(t, 0, len(c.touch(testCacheKey("f"), testCacheKey("1"), 2)))
+	requireEquals(t, 0, len(c.touch(testCacheKey("f"), testCacheKey("2"), 2)))
+	requireEquals(t, 0, len(c.touch(testCacheKey("f"), testCacheKey("1"), 2)))
+
+	evicted := c.touch(testCacheKey("f"), testCacheKey("3"), 2)
+	requireEquals(t, []cacheKey{testCacheKey("2")}, evictedKeys(evicted))
+	requireEquals(t, "2", evicted[0].val.res.Value)
+	_, ok := c.get(testCacheKey("2"))
+	requireFalse(t, ok)
+
+	// removed key not touched
+	requireEquals(t, 0, len(c.touch(testCacheKey("f"), testCacheKey("2"), 2)))
+
+	// other fixture has own limit
+	set("4")
+	requireEquals(t, 0, len(c.touch(testCacheKey("f2"), testCacheKey("4"), 1)))
+
+	c.m.Lock()
+	c.deleteKey(testCacheKey("1"))
+	c.deleteKey(testCacheKey("3"))
+	c.deleteKey(testCacheKey("4"))
+	c.m.Unlock()
+	requireEquals(t, 0, len(c.lru))
+	requireEquals(t, 0, len(c.lruKeys))
+}
+
 func TestCache_GetOrSetRaceCondition(_ *testing.T) {
 	parallels := 100
 	iterations := 1000
@@ -300,7 +434,7 @@ func TestCache_GetOrSetRaceCondition(_ *testing.T) {
 			defer wg.Done()
 
 			for j := 0; j < iterations; j++ {
-				key := cacheKey(strconv.Itoa(rand.Intn(rndMaxBound)))
+				key := testCacheKey(strconv.Itoa(rand.Intn(rndMaxBound)))
 				v, ok := c.GetOrSet(key, func() (res *Result, err error) {
 					return NewResult(1), nil
 				})
@@ -311,3 +445,57 @@ func TestCache_GetOrSetRaceCondition(_ *testing.T) {
 	}
 	wg.Wait()
 }
+
+func BenchmarkCache_GetOrSetParallel(b *testing.B) {
+	c := newCache()
+	keys := make([]cacheKey, 100)
+	for i := range keys {
+		keys[i] = testCacheKey(strconv.Itoa(i))
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			_, _ = c.GetOrSet(keys[i%len(keys)], func() (*Result, error) {
+				return NewResult(1), nil
+			})
+			i++
+		}
+	})
+}
+
+// BenchmarkEnv_CacheResultParallel emulate many parallel tests, which use fixtures
+// of own test scope and of shared package scope.
+func BenchmarkEnv_CacheResultParallel(b *testing.B) {
+	for _, scope := range []CacheScope{ScopeTest, ScopePackage} {
+		b.Run(scope.String(), func(b *testing.B) {
+			m := &sync.Mutex{}
+			scopes := make(map[string]*scopeInfo)
+			packageMock := &internal.TestMock{TestName: packageScopeName}
+			newEnv(packageMock, m, scopes).onCreate()
+			defer packageMock.CallCleanup()
+
+			fixture := func(e Env, key int) int {
+				return e.CacheResult(func() (*Result, error) {
+					return NewResult(key), nil
+				}, CacheOptions{Scope: scope, CacheKey: key}).(int)
+			}
+
+			var testID int64
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				tMock := &internal.TestMock{TestName: "test-" + strconv.FormatInt(atomic.AddInt64(&testID, 1), 10)}
+				e := newEnv(tMock, m, scopes)
+				e.onCreate()
+				defer tMock.CallCleanup()
+
+				i := 0
+				for pb.Next() {
+					fixture(e, i%10)
+					i++
+				}
+			})
+		})
+	}
+}
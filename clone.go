@@ -0,0 +1,313 @@
+package fixenv
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CheckMutationEnvName is name of environment variable, which enable mutation check
+// for fixtures with CacheOptions.CloneOnRead: FIXENV_CHECK_MUTATION=1
+// It is same as CreateMainTestEnvOpts.CheckMutation.
+const CheckMutationEnvName = "FIXENV_CHECK_MUTATION"
+
+// Cloner is implemented by values, which can clone self for CacheOptions.CloneOnRead.
+// Clone must return deep copy of the value with same type.
+type Cloner interface {
+	Clone() interface{}
+}
+
+var (
+	cloneFuncsMutex sync.RWMutex
+	cloneFuncs      = map[reflect.Type]reflect.Value{}
+)
+
+func init() {
+	// time.Time has unexported fields, but it is immutable value
+	RegisterCloneFunc(func(t time.Time) time.Time { return t })
+}
+
+// RegisterCloneFunc register clone function for type T for CacheOptions.CloneOnRead.
+// f must have signature func(T) T, it has priority over Cloner interface and default deep copy.
+// Use it for types from other packages, which have unexported fields.
+// RegisterCloneFunc panics if f has other signature.
+func RegisterCloneFunc(f interface{}) {
+	fVal := reflect.ValueOf(f)
+	fType := fVal.Type()
+	if fType.Kind() != reflect.Func || fType.NumIn() != 1 || fType.NumOut() != 1 || fType.In(0) != fType.Out(0) {
+		panic(fmt.Errorf("fixenv: clone function must have signature func(T) T, got: %v", fType))
+	}
+
+	cloneFuncsMutex.Lock()
+	defer cloneFuncsMutex.Unlock()
+
+	cloneFuncs[fType.In(0)] = fVal
+}
+
+func getCloneFunc(t reflect.Type) (reflect.Value, bool) {
+	cloneFuncsMutex.RLock()
+	defer cloneFuncsMutex.RUnlock()
+
+	f, ok := cloneFuncs[t]
+	return f, ok
+}
+
+// checkMutationOpt is non zero if mutation check enabled from CreateMainTestEnvOpts
+var checkMutationOpt int32
+
+func setCheckMutation(enabled bool) {
+	var val int32
+	if enabled {
+		val = 1
+	}
+	atomic.StoreInt32(&checkMutationOpt, val)
+}
+
+func checkMutationEnabled() bool {
+	if atomic.LoadInt32(&checkMutationOpt) != 0 {
+		return true
+	}
+	val, err := strconv.ParseBool(os.Getenv(CheckMutationEnvName))
+	return err == nil && val
+}
+
+// cloneValue return deep copy of v with same type.
+// It use registered clone function, Cloner interface or copy value by reflection.
+func cloneValue(v interface{}) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	res, err := (&cloner{copies: map[clonePointer]reflect.Value{}}).clone(reflect.ValueOf(v))
+	if err != nil {
+		return nil, err
+	}
+	return res.Interface(), nil
+}
+
+// cloner is state of one deep copy, it keep copies of pointers for cycles and shared values
+type cloner struct {
+	copies map[clonePointer]reflect.Value
+}
+
+// clonePointer is identity of pointer for cloner. Address is not enough:
+// pointer to struct and pointer to its first field have same address.
+type clonePointer struct {
+	typ  reflect.Type
+	addr uintptr
+}
+
+func (c *cloner) clone(v reflect.Value) (reflect.Value, error) {
+	if f, ok := getCloneFunc(v.Type()); ok {
+		return f.Call([]reflect.Value{v})[0], nil
+	}
+	if v.CanInterface() {
+		if cl, ok := v.Interface().(Cloner); ok && !isNilValue(v) {
+			res := reflect.ValueOf(cl.Clone())
+			if !res.IsValid() || res.Type() != v.Type() {
+				return reflect.Value{}, fmt.Errorf("clone of %v returned other type: %v", v.Type(), res)
+			}
+			return res, nil
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128, reflect.String:
+		return v, nil
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v, nil
+		}
+		ptr := clonePointer{typ: v.Type(), addr: v.Pointer()}
+		if res, ok := c.copies[ptr]; ok {
+			return res, nil
+		}
+		res := reflect.New(v.Type().Elem())
+		c.copies[ptr] = res
+		elem, err := c.clone(v.Elem())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		res.Elem().Set(elem)
+		return res, nil
+	case reflect.Interface:
+		if v.IsNil() {
+			return v, nil
+		}
+		elem, err := c.clone(v.Elem())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		res := reflect.New(v.Type()).Elem()
+		res.Set(elem)
+		return res, nil
+	case reflect.Slice:
+		if v.IsNil() {
+			return v, nil
+		}
+		res := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		return res, c.cloneItems(res, v)
+	case reflect.Array:
+		res := reflect.New(v.Type()).Elem()
+		return res, c.cloneItems(res, v)
+	case reflect.Map:
+		if v.IsNil() {
+			return v, nil
+		}
+		res := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			item, err := c.clone(iter.Value())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			res.SetMapIndex(iter.Key(), item)
+		}
+		return res, nil
+	case reflect.Struct:
+		res := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath != "" {
+				return reflect.Value{}, fmt.Errorf("type %v has unexported field %v, implement fixenv.Cloner or register clone function",
+					v.Type(), v.Type().Field(i).Name)
+			}
+			field, err := c.clone(v.Field(i))
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			res.Field(i).Set(field)
+		}
+		return res, nil
+	default:
+		return reflect.Value{}, fmt.Errorf("can't clone value of type %v, implement fixenv.Cloner or register clone function", v.Type())
+	}
+}
+
+func (c *cloner) cloneItems(dst, src reflect.Value) error {
+	for i := 0; i < src.Len(); i++ {
+		item, err := c.clone(src.Index(i))
+		if err != nil {
+			return err
+		}
+		dst.Index(i).Set(item)
+	}
+	return nil
+}
+
+func isNilValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map, reflect.Func, reflect.Chan:
+		return v.IsNil()
+	default:
+		return false
+	}
+}
+
+// valueDigest return hash of deep content of v, for detect mutations of the value
+func valueDigest(v interface{}) (string, error) {
+	return (&digester{h: sha256.New(), visited: map[uintptr]bool{}}).digest(reflect.ValueOf(v))
+}
+
+type digester struct {
+	h hash.Hash
+
+	// visited is pointers on path from root to current value, for detect cycles
+	visited map[uintptr]bool
+}
+
+func (d *digester) write(v reflect.Value) error {
+	if !v.IsValid() {
+		_, _ = io.WriteString(d.h, "invalid;")
+		return nil
+	}
+
+	_, _ = fmt.Fprintf(d.h, "%v:", v.Type())
+	switch v.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128, reflect.String:
+		_, _ = fmt.Fprintf(d.h, "%#v;", v)
+		return nil
+	case reflect.Ptr:
+		if v.IsNil() {
+			_, _ = io.WriteString(d.h, "nil;")
+			return nil
+		}
+		// visited is pointers of current path only, shared pointers digested every time
+		// for same digest independent of map iteration order
+		if d.visited[v.Pointer()] {
+			_, _ = io.WriteString(d.h, "cycle;")
+			return nil
+		}
+		d.visited[v.Pointer()] = true
+		defer delete(d.visited, v.Pointer())
+		return d.write(v.Elem())
+	case reflect.Interface:
+		if v.IsNil() {
+			_, _ = io.WriteString(d.h, "nil;")
+			return nil
+		}
+		return d.write(v.Elem())
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			_, _ = io.WriteString(d.h, "nil;")
+			return nil
+		}
+		_, _ = fmt.Fprintf(d.h, "len %v;", v.Len())
+		for i := 0; i < v.Len(); i++ {
+			if err := d.write(v.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		if v.IsNil() {
+			_, _ = io.WriteString(d.h, "nil;")
+			return nil
+		}
+		// map order is random, then sort items by digest of key
+		items := make([]string, 0, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			keyDigest, err := (&digester{h: sha256.New(), visited: map[uintptr]bool{}}).digest(iter.Key())
+			if err != nil {
+				return err
+			}
+			valDigest, err := (&digester{h: sha256.New(), visited: d.visited}).digest(iter.Value())
+			if err != nil {
+				return err
+			}
+			items = append(items, keyDigest+"="+valDigest)
+		}
+		sort.Strings(items)
+		_, _ = fmt.Fprintf(d.h, "%v;", items)
+		return nil
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if err := d.write(v.Field(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("can't check mutation of value with type %v", v.Type())
+	}
+}
+
+func (d *digester) digest(v reflect.Value) (string, error) {
+	if err := d.write(v); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(d.h.Sum(nil)), nil
+}
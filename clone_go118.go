@@ -0,0 +1,9 @@
+//go:build go1.18
+// +build go1.18
+
+package fixenv
+
+// RegisterClone is typed version of RegisterCloneFunc
+func RegisterClone[T any](f func(T) T) {
+	RegisterCloneFunc(f)
+}
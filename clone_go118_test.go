@@ -0,0 +1,26 @@
+//go:build go1.18
+// +build go1.18
+
+package fixenv
+
+import "testing"
+
+type cloneTestGeneric struct {
+	val []int
+}
+
+func TestRegisterClone(t *testing.T) {
+	RegisterClone(func(v *cloneTestGeneric) *cloneTestGeneric {
+		return &cloneTestGeneric{val: append([]int(nil), v.val...)}
+	})
+
+	e := newTestEnv(t)
+	fixture := func() *cloneTestGeneric {
+		return CacheResult(e, func() (*GenericResult[*cloneTestGeneric], error) {
+			return NewGenericResult(&cloneTestGeneric{val: []int{1}}), nil
+		}, CacheOptions{CloneOnRead: true})
+	}
+
+	fixture().val[0] = 2
+	requireEquals(t, 1, fixture().val[0])
+}
@@ -0,0 +1,259 @@
+package fixenv
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rekby/fixenv/internal"
+)
+
+type cloneTestConfig struct {
+	Name  string
+	Tags  []string
+	Attrs map[string]*cloneTestConfig
+	Any   interface{}
+	Arr   [2]int
+	Time  time.Time
+	Self  *cloneTestConfig
+}
+
+type cloneTestCloner struct {
+	val []int
+}
+
+func (c *cloneTestCloner) Clone() interface{} {
+	return &cloneTestCloner{val: append([]int(nil), c.val...)}
+}
+
+type cloneTestUnexported struct {
+	val []int
+}
+
+type cloneTestRegistered struct {
+	val []int
+}
+
+func TestCloneValue(t *testing.T) {
+	t.Run("reflect", func(t *testing.T) {
+		src := &cloneTestConfig{
+			Name:  "a",
+			Tags:  []string{"t1"},
+			Attrs: map[string]*cloneTestConfig{"b": {Name: "b"}},
+			Any:   []int{1},
+			Arr:   [2]int{1, 2},
+			Time:  time.Now(),
+		}
+		src.Self = src
+
+		res, err := cloneValue(src)
+		noError(t, err)
+		dst := res.(*cloneTestConfig)
+		requireEquals(t, src.Name, dst.Name)
+		requireEquals(t, src.Tags, dst.Tags)
+		requireEquals(t, src.Arr, dst.Arr)
+		requireTrue(t, src.Time.Equal(dst.Time))
+		requireTrue(t, dst.Self == dst)
+
+		dst.Tags[0] = "changed"
+		dst.Attrs["b"].Name = "changed"
+		dst.Any.([]int)[0] = 2
+		requireEquals(t, "t1", src.Tags[0])
+		requireEquals(t, "b", src.Attrs["b"].Name)
+		requireEquals(t, 1, src.Any.([]int)[0])
+	})
+
+	t.Run("same_address", func(t *testing.T) {
+		type inner struct {
+			X int
+		}
+		type outer struct {
+			S  *inner
+			XP *int
+		}
+		s := &inner{X: 1}
+		src := outer{S: s, XP: &s.X}
+
+		res, err := cloneValue(src)
+		noError(t, err)
+		dst := res.(outer)
+		requireEquals(t, 1, dst.S.X)
+		requireEquals(t, 1, *dst.XP)
+		requireTrue(t, dst.S != s)
+		requireTrue(t, dst.XP != &s.X)
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		res, err := cloneValue(nil)
+		noError(t, err)
+		requireNil(t, res)
+	})
+
+	t.Run("cloner", func(t *testing.T) {
+		src := &cloneTestCloner{val: []int{1}}
+		res, err := cloneValue(src)
+		noError(t, err)
+		res.(*cloneTestCloner).val[0] = 2
+		requireEquals(t, 1, src.val[0])
+	})
+
+	t.Run("registered", func(t *testing.T) {
+		RegisterCloneFunc(func(v cloneTestRegistered) cloneTestRegistered {
+			return cloneTestRegistered{val: append([]int(nil), v.val...)}
+		})
+		src := cloneTestRegistered{val: []int{1}}
+		res, err := cloneValue(src)
+		noError(t, err)
+		res.(cloneTestRegistered).val[0] = 2
+		requireEquals(t, 1, src.val[0])
+
+		requirePanic(t, func() {
+			RegisterCloneFunc(func(v int) string { return "" })
+		})
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		for _, v := range []interface{}{
+			cloneTestUnexported{},
+			make(chan int),
+			func() {},
+		} {
+			_, err := cloneValue(v)
+			requireNotNil(t, err)
+		}
+	})
+}
+
+func TestValueDigest(t *testing.T) {
+	val := &cloneTestConfig{
+		Name:  "a",
+		Attrs: map[string]*cloneTestConfig{"b": {Name: "b"}, "c": {Name: "c"}},
+	}
+	val.Self = val
+
+	d1, err := valueDigest(val)
+	noError(t, err)
+	for i := 0; i < 10; i++ {
+		d, err := valueDigest(val)
+		noError(t, err)
+		requireEquals(t, d1, d)
+	}
+
+	val.Attrs["b"].Name = "changed"
+	d2, err := valueDigest(val)
+	noError(t, err)
+	requireNotEquals(t, d1, d2)
+
+	_, err = valueDigest(func() {})
+	requireNotNil(t, err)
+}
+
+func TestValueDigest_SharedPointers(t *testing.T) {
+	shared := &cloneTestConfig{Name: "shared"}
+	val := map[string]*cloneTestConfig{}
+	for i := 0; i < 10; i++ {
+		val[strconv.Itoa(i)] = shared
+	}
+
+	d1, err := valueDigest(val)
+	noError(t, err)
+	for i := 0; i < 200; i++ {
+		d, err := valueDigest(val)
+		noError(t, err)
+		requireEquals(t, d1, d)
+	}
+}
+
+func TestCloneOnRead(t *testing.T) {
+	newEnvs := func() (newTest func(name string) (*EnvT, *internal.TestMock)) {
+		m := &sync.Mutex{}
+		scopes := make(map[string]*scopeInfo)
+		newEnv(&internal.TestMock{TestName: packageScopeName}, m, scopes).onCreate()
+
+		return func(name string) (*EnvT, *internal.TestMock) {
+			tMock := &internal.TestMock{TestName: name}
+			e := newEnv(tMock, m, scopes)
+			e.onCreate()
+			return e, tMock
+		}
+	}
+
+	fixture := func(e Env) []int {
+		return e.CacheResult(func() (*Result, error) {
+			return NewResult([]int{1}), nil
+		}, CacheOptions{Scope: ScopePackage, CloneOnRead: true}).([]int)
+	}
+
+	t.Run("clone", func(t *testing.T) {
+		newTest := newEnvs()
+		e, _ := newTest("t1")
+		fixture(e)[0] = 2
+		requireEquals(t, []int{1}, fixture(e))
+	})
+
+	t.Run("clone_error", func(t *testing.T) {
+		newTest := newEnvs()
+		e, tMock := newTest("t1")
+		runUntilFatal(func() {
+			e.CacheResult(func() (*Result, error) {
+				return NewResult(cloneTestUnexported{}), nil
+			}, CacheOptions{CloneOnRead: true})
+		})
+		requireEquals(t, 1, len(tMock.Fatals))
+	})
+
+	t.Run("check_mutation", func(t *testing.T) {
+		setCheckMutation(true)
+		defer setCheckMutation(false)
+
+		newTest := newEnvs()
+		e1, t1 := newTest("t1")
+		e2, t2 := newTest("t2")
+
+		fixture(e1)[0] = 2
+		fixture(e2)
+
+		t1.CallCleanup()
+		requireTrue(t, t1.Failed())
+		requireEquals(t, 1, len(t1.Logs))
+		requireTrue(t, strings.Contains(t1.Logs[0].ResultString, "changed shared value"))
+
+		t2.CallCleanup()
+		requireFalse(t, t2.Failed())
+	})
+
+	t.Run("check_mutation_with_reset", func(t *testing.T) {
+		setCheckMutation(true)
+		defer setCheckMutation(false)
+
+		newTest := newEnvs()
+		shared := []int{1}
+		resets := 0
+		fixture := func(e Env) []int {
+			return e.CacheResult(func() (*Result, error) {
+				res := NewResult(shared)
+				res.Reset = func() {
+					resets++
+					shared[0] = 1
+				}
+				return res, nil
+			}, CacheOptions{Scope: ScopePackage, CloneOnRead: true}).([]int)
+		}
+
+		e1, t1 := newTest("t1")
+		fixture(e1)[0] = 2
+
+		t1.CallCleanup()
+		requireTrue(t, t1.Failed())
+		requireEquals(t, 1, resets)
+		requireTrue(t, strings.Contains(t1.Logs[0].ResultString, "changed shared value"))
+
+		// reset restored the value
+		e2, t2 := newTest("t2")
+		requireEquals(t, []int{1}, fixture(e2))
+		t2.CallCleanup()
+		requireFalse(t, t2.Failed())
+	})
+}
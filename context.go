@@ -0,0 +1,17 @@
+package fixenv
+
+import "context"
+
+type envContextKey struct{}
+
+// WithEnv return copy of ctx, which carry env.
+// Code under test, which receive context only, can get env by FromContext and call fixtures lazily.
+func WithEnv(ctx context.Context, env Env) context.Context {
+	return context.WithValue(ctx, envContextKey{}, env)
+}
+
+// FromContext return env, saved in ctx by WithEnv.
+func FromContext(ctx context.Context) (Env, bool) {
+	env, ok := ctx.Value(envContextKey{}).(Env)
+	return env, ok
+}
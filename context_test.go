@@ -0,0 +1,26 @@
+package fixenv
+
+import (
+	"context"
+	"testing"
+)
+
+func TestEnvContext(t *testing.T) {
+	e := newTestEnv(t)
+
+	env, ok := FromContext(context.Background())
+	requireFalse(t, ok)
+	requireNil(t, env)
+
+	ctx := WithEnv(context.Background(), e)
+	env, ok = FromContext(ctx)
+	requireTrue(t, ok)
+	requireEquals(t, e, env)
+
+	// child context carry env too
+	childCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	env, ok = FromContext(childCtx)
+	requireTrue(t, ok)
+	requireEquals(t, e, env)
+}
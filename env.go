@@ -1,27 +1,37 @@
 package fixenv
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
-	"path/filepath"
 	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 )
 
 const packageScopeName = "TestMain"
 
-var (
-	globalCache *cache
+// flags of functions, which call Helper of test once per env, see EnvT.needHelper
+const (
+	helperCacheResult int32 = 1 << iota
+	helperCache
+	helperGenericCacheResult
+	helperCacheResultKey
+	helperCacheResultKey2
+)
 
+// lifecycle states of EnvT
+const (
+	envStateActive int32 = iota
+	envStateTornDown
+)
+
+var (
 	globalMutex     sync.Mutex
 	globalScopeInfo map[string]*scopeInfo
 )
 
 func initGlobalState() {
-	globalCache = newCache()
-
 	globalMutex = sync.Mutex{}
 	globalScopeInfo = make(map[string]*scopeInfo)
 }
@@ -37,28 +47,63 @@ func init() {
 // It can be base to own, more powerful local environments.
 type EnvT struct {
 	t T
-	c *cache
 
 	m      sync.Locker
 	scopes map[string]*scopeInfo
+
+	// scopeInfos is cache of scopes, used by the env: scope name -> *scopeInfo.
+	// It allow get cache shard of scope without lock of global mutex.
+	scopeInfos sync.Map
+
+	benchmarkM          sync.Mutex
+	benchmarkTimerStops int
+
+	// state is lifecycle state of env: envStateActive or envStateTornDown
+	state int32
+
+	// parent is env of parent test, if the env created by Run
+	parent *EnvT
+
+	// initStacks is stacks of fixtures in progress of initialization by goroutine id,
+	// for detect scope violations. inits is count of fixtures in progress of initialization.
+	initM      sync.Mutex
+	initStacks map[uint64][]initFrame
+	inits      int32
+
+	// helpers is flags of env functions, marked as test helpers already
+	helpers int32
 }
 
-// New create EnvT from test
+// New create EnvT from test.
+// It may be called some times for same test, all envs of the test share cache and scope.
 func New(t T) *EnvT {
-	env := newEnv(t, globalCache, &globalMutex, globalScopeInfo)
+	env := newEnv(t, &globalMutex, globalScopeInfo)
 	env.onCreate()
 	return env
 }
 
-func newEnv(t T, c *cache, m sync.Locker, scopes map[string]*scopeInfo) *EnvT {
+func newEnv(t T, m sync.Locker, scopes map[string]*scopeInfo) *EnvT {
 	return &EnvT{
 		t:      t,
-		c:      c,
 		m:      m,
 		scopes: scopes,
 	}
 }
 
+// needHelper return true once per env for every flag.
+// T remember helper functions for whole test, but Helper call is expensive for every fixture call.
+func (e *EnvT) needHelper(flag int32) bool {
+	for {
+		old := atomic.LoadInt32(&e.helpers)
+		if old&flag != 0 {
+			return false
+		}
+		if atomic.CompareAndSwapInt32(&e.helpers, old, old|flag) {
+			return true
+		}
+	}
+}
+
 // T return test from EnvT created
 func (e *EnvT) T() T {
 	return e.t
@@ -69,6 +114,10 @@ func (e *EnvT) T() T {
 // f with same options calls max once per test (or defined test scope)
 // See to generic wrapper: CacheResult
 func (e *EnvT) CacheResult(f FixtureFunction, options ...CacheOptions) interface{} {
+	if ht, ok := e.t.(helperT); ok && e.needHelper(helperCacheResult) {
+		ht.Helper()
+	}
+
 	var cacheOptions CacheOptions
 	switch len(options) {
 	case 0:
@@ -86,80 +135,171 @@ func (e *EnvT) CacheResult(f FixtureFunction, options ...CacheOptions) interface
 // cache must be call from first-level public function
 // UserFunction->EnvFunction->cache for good determine caller name
 func (e *EnvT) cache(f FixtureFunction, options CacheOptions) interface{} {
-	key, err := makeCacheKey(e.t.Name(), options, false)
+	if ht, ok := e.t.(helperT); ok && e.needHelper(helperCache) {
+		ht.Helper()
+	}
+
+	if atomic.LoadInt32(&e.state) == envStateTornDown {
+		e.t.Fatalf("fixenv: env of test %q used after the test finished, fixture: \"%v\"",
+			e.t.Name(), fixtureDescription(options))
+		// return not reachable after Fatalf
+		return nil
+	}
+
+	scopeName := e.scopeName(options.Scope)
+	si := e.scopeInfo(scopeName)
+	if si == nil {
+		e.t.Fatalf("Unexpected scope: %q. Initialize package scope before use."+
+			"For scope %s use fixenv.RunTests", scopeName, packageScopeName)
+		// return not reachable after Fatalf
+		return nil
+	}
+
+	key, err := makeCacheKey(scopeName, options, false)
 	if err != nil {
 		e.t.Fatalf("failed to create cache key: %v", err)
 		// return not reacheble after Fatalf
 		return nil
 	}
 
+	e.checkScope(key, options.Scope)
+
 	wrappedF := e.fixtureCallWrapper(key, f, options)
-	res, err := e.c.GetOrSet(key, wrappedF)
+	val, err := e.getOrSet(si, key, wrappedF)
 	if err != nil {
 		if errors.Is(err, ErrSkipTest) {
+			if err != ErrSkipTest {
+				// wrapped skip error contains reason of skip
+				e.t.Logf("fixenv: %v", err)
+			}
 			e.T().SkipNow()
 		} else {
-			// Get fixture name
-			externalCallerLevel := 4
-			var pc = make([]uintptr, externalCallerLevel)
-			var extCallerFrame runtime.Frame
-			if externalCallerLevel == runtime.Callers(options.additionlSkipExternalCalls, pc) {
-				frames := runtime.CallersFrames(pc)
-				frames.Next()                     // callers
-				frames.Next()                     // the function
-				frames.Next()                     // caller of the function (env private function)
-				extCallerFrame, _ = frames.Next() // external caller
-			}
-
-			fixtureDesctiption := fmt.Sprintf(
-				"%v (%v:%v)",
-				extCallerFrame.Function,
-				extCallerFrame.File,
-				extCallerFrame.Line,
-			)
-			e.t.Fatalf("failed to call fixture func \"%v\": %v", fixtureDesctiption, err)
+			e.t.Fatalf("failed to call fixture func \"%v\": %v", fixtureDescription(options), err)
 		}
 
 		// panic must be not reachable after SkipNow or Fatalf
 		panic("fixenv: must be unreachable code after err check in fixture cache")
 	}
 
-	return res.Value
+	if options.MaxEntries > 0 {
+		e.evict(si, key, options)
+	}
+	if val.res.Reset != nil {
+		e.addReset(options.Scope, key, val.res.Reset)
+	}
+	if options.CloneOnRead {
+		return e.cloneOnRead(key, options.Scope, val)
+	}
+	return val.res.Value
 }
 
-// tearDown called from base test cleanup
-// it clean env cache and call fixture's cleanups for the scope.
-func (e *EnvT) tearDown() {
-	e.m.Lock()
-	defer e.m.Unlock()
+// addMutationCheck register mutation check of fixture for call at end of the test, if fixture has wider scope.
+// Checks are separate from resets, because fixture with Reset must be checked too.
+func (e *EnvT) addMutationCheck(scope CacheScope, key cacheKey, check mutationCheck) {
+	testName := e.t.Name()
+	if e.scopeName(scope) == testName {
+		return
+	}
 
+	if si := e.scopeInfo(testName); si != nil {
+		si.AddMutationCheck(key, check)
+	}
+}
+
+// addReset register reset of fixture for call at end of the test, if fixture has wider scope
+func (e *EnvT) addReset(scope CacheScope, key cacheKey, reset FixtureResetFunc) {
 	testName := e.t.Name()
-	if si, ok := e.scopes[testName]; ok {
-		cacheKeys := si.Keys()
-		e.c.DeleteKeys(cacheKeys...)
-		delete(e.scopes, testName)
-	} else {
-		e.t.Fatalf("unexpected call env tearDown for test: %q", testName)
+	if e.scopeName(scope) == testName {
+		return
+	}
+
+	if si := e.scopeInfo(testName); si != nil {
+		si.AddReset(key, reset)
 	}
 }
 
-// onCreate register env in internal stuctures.
-func (e *EnvT) onCreate() {
-	e.m.Lock()
-	defer e.m.Unlock()
+// getOrSet get value from cache, check it and re-create if the cached value is stale: expired or invalid
+func (e *EnvT) getOrSet(si *scopeInfo, key cacheKey, f FixtureFunction) (cacheVal, error) {
+	for {
+		val, created := si.c.getOrSet(key, f)
+		if created || val.err != nil {
+			return val, val.err
+		}
 
-	testName := e.t.Name()
-	if _, ok := e.scopes[testName]; ok {
-		e.t.Fatalf("Env exist already for scope: %q", testName)
-	} else {
-		e.scopes[testName] = newScopeInfo(e.t)
-		e.t.Cleanup(e.tearDown)
+		err := val.hit()
+		if err == nil {
+			err = val.validate()
+		}
+		if err == nil {
+			return val, nil
+		}
+		if si.c.delete(key, val) {
+			// key will be added again by new value
+			si.RemoveKey(key)
+			e.t.Logf("fixenv: cached value is stale, create new. Reason: %v, fixture: %v", err, key)
+			if val.res.Cleanup != nil {
+				val.res.Cleanup()
+			}
+		}
+	}
+}
+
+// evict mark key as recently used and cleanup least recently used values of the fixture
+// over options.MaxEntries
+func (e *EnvT) evict(si *scopeInfo, key cacheKey, options CacheOptions) {
+	for _, item := range si.c.touch(key.fixtureKey(), key, options.MaxEntries) {
+		si.RemoveKey(item.key)
+		if item.val.res != nil && item.val.res.Cleanup != nil {
+			item.val.res.Cleanup()
+		}
 	}
 }
 
-// makeCacheKey generate cache key
-// must be called from first level of env functions - for detect external caller
-func makeCacheKey(testname string, options CacheOptions, testCall bool) (cacheKey, error) {
+// cloneOnRead return clone of cached value.
+// In check mutation mode it return shared value and check, that the test doesn't change it.
+func (e *EnvT) cloneOnRead(key cacheKey, scope CacheScope, val cacheVal) interface{} {
+	if ht, ok := e.t.(helperT); ok {
+		ht.Helper()
+	}
+
+	if checkMutationEnabled() {
+		e.checkMutation(key, scope, val)
+		return val.res.Value
+	}
+
+	res, err := cloneValue(val.res.Value)
+	if err != nil {
+		e.t.Fatalf("fixenv: failed to clone value of fixture %v: %v", key, err)
+		// return not reachable after Fatalf
+		return nil
+	}
+	return res
+}
+
+// checkMutation register check of value digest at end of the test
+func (e *EnvT) checkMutation(key cacheKey, scope CacheScope, val cacheVal) {
+	if _, err := val.stat.updateDigest(val.res.Value, true); err != nil {
+		e.t.Logf("fixenv: skip mutation check of fixture %v: %v", key, err)
+		return
+	}
+
+	e.addMutationCheck(scope, key, mutationCheck{
+		check: func() {
+			// report only first test, which changed the value: new digest saved
+			if changed, _ := val.stat.updateDigest(val.res.Value, false); changed {
+				failTest(e.t, "fixenv: test %q changed shared value of fixture %v", e.t.Name(), key)
+			}
+		},
+		rebase: func() {
+			// reset may restore value, changed by the test
+			_, _ = val.stat.updateDigest(val.res.Value, false)
+		},
+	})
+}
+
+// fixtureDescription return name and position of fixture function.
+// must be called from EnvT.cache only - for detect external caller
+func fixtureDescription(options CacheOptions) string {
 	externalCallerLevel := 5
 	var pc = make([]uintptr, externalCallerLevel)
 	var extCallerFrame runtime.Frame
@@ -171,53 +311,95 @@ func makeCacheKey(testname string, options CacheOptions, testCall bool) (cacheKe
 		frames.Next()                     // caller of private function (env public function)
 		extCallerFrame, _ = frames.Next() // external caller
 	}
-	scopeName := makeScopeName(testname, options.Scope)
-	return makeCacheKeyFromFrame(options.CacheKey, options.Scope, extCallerFrame, scopeName, testCall)
+
+	return fmt.Sprintf(
+		"%v (%v:%v)",
+		extCallerFrame.Function,
+		extCallerFrame.File,
+		extCallerFrame.Line,
+	)
 }
 
-func makeCacheKeyFromFrame(params interface{}, scope CacheScope, f runtime.Frame, scopeName string, testCall bool) (cacheKey, error) {
-	switch {
-	case f.Function == "":
-		return "", errors.New("failed to detect caller func name")
-	case f.File == "":
-		return "", errors.New("failed to detect caller func file")
-	default:
-		// pass
-	}
+// tearDown called from base test cleanup
+// it clean env cache and call fixture's cleanups for the scope.
+func (e *EnvT) tearDown() {
+	var finished *scopeInfo
+	var mutationChecks []mutationCheck
+	var resets []FixtureResetFunc
+	defer func() {
+		if finished == nil {
+			return
+		}
 
-	key := struct {
-		Scope        CacheScope  `json:"scope"`
-		ScopeName    string      `json:"scope_name"`
-		FunctionName string      `json:"func"`
-		FileName     string      `json:"fname"`
-		Params       interface{} `json:"params"`
-	}{
-		Scope:        scope,
-		ScopeName:    scopeName,
-		FunctionName: f.Function,
-		FileName:     f.File,
-		Params:       params,
-	}
-	if testCall {
-		key.FileName = ".../" + filepath.Base(key.FileName)
-	}
+		// hold scope without fixture cleanups. It called after unlock for not block other tests,
+		// values of the scope still in its cache shard.
+		finished.beforeCleanup.Do(func() {
+			e.hold(finished)
+		})
 
-	keyBytes, err := json.Marshal(key)
-	if err != nil {
-		return "", fmt.Errorf("failed to serialize params to json: %v", err)
+		// call resets after unlock, because reset is user code and may be slow.
+		// Mutation checks called before resets, because reset may restore changed value.
+		for _, check := range mutationChecks {
+			check.check()
+		}
+		for _, reset := range resets {
+			reset()
+		}
+		if len(resets) > 0 {
+			for _, check := range mutationChecks {
+				check.rebase()
+			}
+		}
+	}()
+
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	atomic.StoreInt32(&e.state, envStateTornDown)
+
+	testName := e.t.Name()
+	if si, ok := e.scopes[testName]; ok {
+		// other envs of the test still alive
+		si.envs--
+		if si.envs > 0 {
+			return
+		}
+
+		// cache shard of the scope removed with scope info
+		finished = si
+		mutationChecks = si.MutationChecks()
+		resets = si.Resets()
+		delete(e.scopes, testName)
+	} else {
+		e.t.Fatalf("unexpected call env tearDown for test: %q", testName)
 	}
-	return cacheKey(keyBytes), nil
+}
 
+// onCreate register env in internal stuctures.
+// Some envs of same test share one scope, the scope removed after last env teardown.
+func (e *EnvT) onCreate() {
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	testName := e.t.Name()
+	if si, ok := e.scopes[testName]; ok {
+		if si.t != e.t {
+			e.t.Fatalf("Env exist already for scope: %q", testName)
+			return
+		}
+		si.envs++
+	} else {
+		si = newScopeInfo(e.t)
+		si.envs = 1
+		e.scopes[testName] = si
+	}
+	e.t.Cleanup(e.tearDown)
 }
 
 func (e *EnvT) fixtureCallWrapper(key cacheKey, f FixtureFunction, options CacheOptions) FixtureFunction {
 	return func() (res *Result, err error) {
-		scopeName := makeScopeName(e.t.Name(), options.Scope)
-
-		e.m.Lock()
-		si := e.scopes[scopeName]
-		e.m.Unlock()
-
+		scopeName := e.scopeName(options.Scope)
+		si := e.scopeInfo(scopeName)
 		if si == nil {
 			e.t.Fatalf("Unexpected scope: %q. Initialize package scope before use."+
 				"For scope %s use fixenv.RunTests", scopeName, packageScopeName)
@@ -229,6 +411,17 @@ func (e *EnvT) fixtureCallWrapper(key cacheKey, f FixtureFunction, options Cache
 			si.AddKey(key)
 		}()
 
+		if e.stopBenchmarkTimer(options) {
+			defer e.startBenchmarkTimer()
+		}
+
+		// nothing is narrower than ScopeTest, then fixtures of ScopeTest are not tracked:
+		// dependencies of the fixture checked with outer fixture of wider scope
+		if options.Scope != ScopeTest {
+			e.pushInit(key, options.Scope)
+			defer e.popInit()
+		}
+
 		res, err = f()
 
 		// force exactly least one of res, err != nil
@@ -236,13 +429,69 @@ func (e *EnvT) fixtureCallWrapper(key cacheKey, f FixtureFunction, options Cache
 			res = NewResult(nil)
 		}
 		if res != nil && res.Cleanup != nil {
-			si.t.Cleanup(res.Cleanup)
+			res = withCleanupOnce(res)
+			si.t.Cleanup(e.fixtureCleanup(si, key, res))
 		}
 
 		return res, err
 	}
 }
 
+// fixtureCleanup wrap fixture cleanup for hold before teardown of the scope
+// and skip cleanup if scope failed and keep on failure mode enabled.
+func (e *EnvT) fixtureCleanup(si *scopeInfo, key cacheKey, res *Result) FixtureCleanupFunc {
+	return func() {
+		si.beforeCleanup.Do(func() {
+			e.hold(si)
+		})
+		if !res.AlwaysCleanup && keepOnFailureEnabled() && isFailed(si.t) {
+			si.t.Logf("fixenv: test failed, keep fixture without cleanup. Value: %v, fixture: %v", res.Value, key)
+			return
+		}
+		res.Cleanup()
+	}
+}
+
+// withCleanupOnce return copy of res, cleanup of the copy can be called some times, but do the work once.
+// Cleanup of stale value called before end of scope, then registered cleanup must skip work.
+func withCleanupOnce(res *Result) *Result {
+	cleanup := res.Cleanup
+	var once sync.Once
+
+	resCopy := *res
+	resCopy.Cleanup = func() {
+		once.Do(cleanup)
+	}
+	return &resCopy
+}
+
+// scopeInfo return info of scope by name or nil if scope not exists.
+// Found scopes are cached in the env, because scope lives longer then env of a test.
+func (e *EnvT) scopeInfo(scopeName string) *scopeInfo {
+	if si, ok := e.scopeInfos.Load(scopeName); ok {
+		return si.(*scopeInfo)
+	}
+
+	e.m.Lock()
+	si := e.scopes[scopeName]
+	e.m.Unlock()
+
+	if si == nil {
+		return nil
+	}
+	e.scopeInfos.Store(scopeName, si)
+	return si
+}
+
+// scopeName return name of scope for env test.
+// ScopeTestAndSubtests resolved to first env of Run chain, if env created by Run.
+func (e *EnvT) scopeName(scope CacheScope) string {
+	if scope == ScopeTestAndSubtests && e.parent != nil {
+		return e.root().scopeName(scope)
+	}
+	return makeScopeName(e.t.Name(), scope)
+}
+
 func makeScopeName(testName string, scope CacheScope) string {
 	switch scope {
 	case ScopePackage:
@@ -250,8 +499,10 @@ func makeScopeName(testName string, scope CacheScope) string {
 	case ScopeTest:
 		return testName
 	case ScopeTestAndSubtests:
-		parts := strings.SplitN(testName, "/", 2)
-		return parts[0]
+		if i := strings.Index(testName, "/"); i >= 0 {
+			return testName[:i]
+		}
+		return testName
 	default:
 		panic(fmt.Sprintf("Unknown scope: %v", scope))
 	}
@@ -8,6 +8,12 @@ import "fmt"
 // CacheResult is call f once per cache scope (default per test) and cache result (success or error).
 // All other calls of the f will return same result.
 func CacheResult[TRes any](env Env, f GenericFixtureFunction[TRes], options ...CacheOptions) TRes {
+	if ht, ok := env.T().(helperT); ok {
+		if e, isEnvT := env.(*EnvT); !isEnvT || e.needHelper(helperGenericCacheResult) {
+			ht.Helper()
+		}
+	}
+
 	var cacheOptions CacheOptions
 	switch len(options) {
 	case 0:
@@ -39,6 +45,90 @@ func CacheResult[TRes any](env Env, f GenericFixtureFunction[TRes], options ...C
 	return res.(TRes)
 }
 
+// CacheResultKey is same as CacheResult, but cache results by key with go equality instead of CacheOptions.CacheKey.
+// Key type checked at compile time and it compared without json serialization.
+// Keys of different types are different keys. CacheOptions.CacheKey must be empty.
+// If K is interface type, dynamic value of the key must be comparable too, else the test fails.
+func CacheResultKey[K comparable, TRes any](env Env, key K, f GenericFixtureFunction[TRes], options ...CacheOptions) TRes {
+	if ht, ok := env.T().(helperT); ok {
+		if e, isEnvT := env.(*EnvT); !isEnvT || e.needHelper(helperCacheResultKey) {
+			ht.Helper()
+		}
+	}
+
+	var cacheOptions CacheOptions
+	switch len(options) {
+	case 0:
+		cacheOptions = CacheOptions{}
+	case 1:
+		cacheOptions = options[0]
+	default:
+		panic(fmt.Errorf("max len of cache result cacheOptions is 1, given: %v", len(options)))
+	}
+	if cacheOptions.CacheKey != nil {
+		panic(fmt.Errorf("CacheOptions.CacheKey must be empty for CacheResultKey, given: %v", cacheOptions.CacheKey))
+	}
+	if err := checkComparableKey(key); err != nil {
+		env.T().Fatalf("fixenv: %v", err)
+		// return not reachable after Fatalf
+		var zero TRes
+		return zero
+	}
+
+	addSkipLevelCache(&cacheOptions)
+	cacheOptions.CacheKey = typedKey[K]{key: key}
+	return CacheResult(env, f, cacheOptions)
+}
+
+// CacheResultKey2 is same as CacheResultKey with tuple of two keys
+func CacheResultKey2[K1, K2 comparable, TRes any](env Env, key1 K1, key2 K2, f GenericFixtureFunction[TRes], options ...CacheOptions) TRes {
+	if ht, ok := env.T().(helperT); ok {
+		if e, isEnvT := env.(*EnvT); !isEnvT || e.needHelper(helperCacheResultKey2) {
+			ht.Helper()
+		}
+	}
+
+	var cacheOptions CacheOptions
+	switch len(options) {
+	case 0:
+		cacheOptions = CacheOptions{}
+	case 1:
+		cacheOptions = options[0]
+	default:
+		panic(fmt.Errorf("max len of cache result cacheOptions is 1, given: %v", len(options)))
+	}
+
+	addSkipLevelCache(&cacheOptions)
+	return CacheResultKey(env, keyTuple2[K1, K2]{key1: key1, key2: key2}, f, cacheOptions)
+}
+
+// typedKey is cache key params of CacheResultKey, it used in cache key as is
+type typedKey[K comparable] struct {
+	key K
+}
+
+func (k typedKey[K]) typedKeyValue() interface{} {
+	return k.key
+}
+
+func (k typedKey[K]) String() string {
+	return fmt.Sprint(k.key)
+}
+
+// keyTuple2 is key of CacheResultKey2
+type keyTuple2[K1, K2 comparable] struct {
+	key1 K1
+	key2 K2
+}
+
+func (k keyTuple2[K1, K2]) typedKeyValue() interface{} {
+	return []interface{}{k.key1, k.key2}
+}
+
+func (k keyTuple2[K1, K2]) String() string {
+	return fmt.Sprintf("(%v, %v)", k.key1, k.key2)
+}
+
 // GenericFixtureFunction - callback function with structured result
 type GenericFixtureFunction[ResT any] func() (*GenericResult[ResT], error)
 
@@ -0,0 +1,24 @@
+//go:build go1.20
+// +build go1.20
+
+package fixenv
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rekby/fixenv/internal"
+)
+
+func TestCacheResultKey_NotComparable(t *testing.T) {
+	tMock := &internal.TestMock{TestName: t.Name()}
+	e := newTestEnv(tMock)
+
+	runUntilFatal(func() {
+		CacheResultKey[any](e, []int{1}, func() (*GenericResult[int], error) {
+			return NewGenericResult(1), nil
+		})
+	})
+	requireEquals(t, 1, len(tMock.Fatals))
+	requireTrue(t, strings.Contains(tMock.Fatals[0].ResultString, "cache key of type []int is not comparable"))
+}
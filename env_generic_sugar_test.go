@@ -117,12 +117,117 @@ func TestCacheResultPanic(t *testing.T) {
 	})
 }
 
+func TestCacheResultKey(t *testing.T) {
+	type tenantID int64
+	type region string
+
+	t.Run("PassParams", func(t *testing.T) {
+		env := envMock{onCacheResult: func(opt CacheOptions, f FixtureFunction) interface{} {
+			requireEquals(t, 2, opt.additionlSkipExternalCalls)
+			requireEquals(t, ScopePackage, opt.Scope)
+			requireEquals(t, typedKey[tenantID]{key: 1}, opt.CacheKey)
+			res, _ := f()
+			return res.Value
+		}}
+
+		res := CacheResultKey(env, tenantID(1), func() (*GenericResult[int], error) {
+			return NewGenericResult(2), nil
+		}, CacheOptions{Scope: ScopePackage})
+		requireEquals(t, 2, res)
+	})
+
+	t.Run("Keys", func(t *testing.T) {
+		e := newTestEnv(&internal.TestMock{TestName: t.Name()})
+
+		calls := 0
+		tenant := func(id tenantID) int {
+			return CacheResultKey(e, id, func() (*GenericResult[int], error) {
+				calls++
+				return NewGenericResult(calls), nil
+			})
+		}
+		other := func(id int64) int {
+			return CacheResultKey(e, id, func() (*GenericResult[int], error) {
+				calls++
+				return NewGenericResult(calls), nil
+			})
+		}
+
+		requireEquals(t, 1, tenant(1))
+		requireEquals(t, 1, tenant(1))
+		requireEquals(t, 2, tenant(2))
+		requireEquals(t, 3, other(1))
+		requireEquals(t, 3, other(1))
+	})
+
+	t.Run("Key2", func(t *testing.T) {
+		e := newTestEnv(&internal.TestMock{TestName: t.Name()})
+
+		calls := 0
+		user := func(id tenantID, r region) int {
+			return CacheResultKey2(e, id, r, func() (*GenericResult[int], error) {
+				calls++
+				return NewGenericResult(calls), nil
+			})
+		}
+
+		requireEquals(t, 1, user(1, "eu"))
+		requireEquals(t, 1, user(1, "eu"))
+		requireEquals(t, 2, user(1, "us"))
+		requireEquals(t, 3, user(2, "eu"))
+	})
+
+	t.Run("Helper", func(t *testing.T) {
+		tMock := &internal.TestMock{TestName: t.Name()}
+		e := newTestEnv(tMock)
+		f := func() (*GenericResult[int], error) {
+			return NewGenericResult(1), nil
+		}
+
+		// CacheResultKey2 first, then both functions must be marked as helpers
+		CacheResultKey2(e, 1, 2, f)
+		requireTrue(t, e.helpers&helperCacheResultKey2 != 0)
+		requireTrue(t, e.helpers&helperCacheResultKey != 0)
+
+		// once per env
+		helpers := tMock.HelperCount
+		CacheResultKey(e, 2, f)
+		CacheResultKey2(e, 1, 3, f)
+		requireEquals(t, helpers, tMock.HelperCount)
+	})
+
+	t.Run("CacheKeyPanic", func(t *testing.T) {
+		e := newTestEnv(&internal.TestMock{TestName: t.Name()})
+		requirePanic(t, func() {
+			CacheResultKey(e, 1, func() (*GenericResult[int], error) {
+				return NewGenericResult(1), nil
+			}, CacheOptions{CacheKey: 1})
+		})
+	})
+}
+
+func BenchmarkCacheResultKey(b *testing.B) {
+	tMock := &internal.TestMock{TestName: "mock"}
+	defer tMock.CallCleanup()
+	e := newTestEnv(tMock)
+
+	f := func() (*GenericResult[int], error) {
+		return NewGenericResult(1), nil
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		CacheResultKey(e, int64(i%10), f)
+	}
+}
+
 type envMock struct {
 	onCacheResult func(opts CacheOptions, f FixtureFunction) interface{}
 }
 
 func (e envMock) T() T {
-	panic("not implemented")
+	return nil
 }
 
 func (e envMock) CacheResult(f FixtureFunction, options ...CacheOptions) interface{} {
@@ -137,3 +242,24 @@ func (e envMock) CacheResult(f FixtureFunction, options ...CacheOptions) interfa
 	}
 	return e.onCacheResult(opts, f)
 }
+
+func TestEnv_Fixtures_CacheResultKey(t *testing.T) {
+	type tenantID int64
+
+	e := newTestEnv(&internal.TestMock{TestName: t.Name()})
+	f := func() (*GenericResult[int], error) {
+		return NewGenericResult(1), nil
+	}
+	CacheResultKey(e, tenantID(3), f)
+	CacheResultKey2(e, tenantID(4), "eu", f)
+
+	fixtures := e.Fixtures()
+	requireEquals(t, 2, len(fixtures))
+	requireEquals(t, tenantID(3), fixtures[0].Key)
+	requireEquals(t, []interface{}{tenantID(4), "eu"}, fixtures[1].Key)
+}
+
+func TestDecodeKeyParams_Typed(t *testing.T) {
+	requireEquals(t, 1, decodeKeyParams(typedKey[int]{key: 1}))
+	requireEquals(t, []interface{}{1, "a"}, decodeKeyParams(typedKey[keyTuple2[int, string]]{key: keyTuple2[int, string]{key1: 1, key2: "a"}}))
+}
@@ -2,21 +2,32 @@ package fixenv
 
 import (
 	"errors"
+	"fmt"
 	"github.com/rekby/fixenv/internal"
 	"math/rand"
 	"runtime"
+	"strings"
 	"sync"
 	"testing"
 )
 
 func (e *EnvT) cloneWithTest(t T) *EnvT {
-	e2 := newEnv(t, e.c, e.m, e.scopes)
+	e2 := newEnv(t, e.m, e.scopes)
 	e2.onCreate()
 	return e2
 }
 
+// cacheSize return count of cached values in all scopes
+func cacheSize(scopes map[string]*scopeInfo) int {
+	res := 0
+	for _, si := range scopes {
+		res += len(si.c.store)
+	}
+	return res
+}
+
 func newTestEnv(t T) *EnvT {
-	e := newEnv(t, newCache(), &sync.Mutex{}, make(map[string]*scopeInfo))
+	e := newEnv(t, &sync.Mutex{}, make(map[string]*scopeInfo))
 	e.onCreate()
 	return e
 }
@@ -30,16 +41,15 @@ func Test_Env__NewEnv(t *testing.T) {
 
 		e := New(tMock)
 		requireEquals(t, tMock, e.t)
-		requireEquals(t, globalCache, e.c)
 		requireEquals(t, &globalMutex, e.m)
 		requireEquals(t, globalScopeInfo, e.scopes)
-		requireEquals(t, len(globalCache.store), 0)
+		requireEquals(t, cacheSize(globalScopeInfo), 0)
 		requireEquals(t, len(globalScopeInfo), 1)
 		requireEquals(t, len(tMock.Cleanups), 1)
 	})
 
 	t.Run("global_info_cleaned", func(t *testing.T) {
-		requireEquals(t, len(globalCache.store), 0)
+		requireEquals(t, cacheSize(globalScopeInfo), 0)
 		requireEquals(t, len(globalScopeInfo), 0)
 	})
 
@@ -50,10 +60,37 @@ func Test_Env__NewEnv(t *testing.T) {
 		_ = New(tMock)
 		requireEquals(t, len(tMock.Fatals), 0)
 
+		tMock2 := &internal.TestMock{TestName: "mock"}
 		runUntilFatal(func() {
-			_ = New(tMock)
+			_ = New(tMock2)
 		})
-		requireEquals(t, len(tMock.Fatals), 1)
+		requireEquals(t, len(tMock2.Fatals), 1)
+	})
+
+	t.Run("double_env_same_test", func(t *testing.T) {
+		tMock := &internal.TestMock{TestName: "mock"}
+		e1 := newTestEnv(tMock)
+		e2 := newEnv(tMock, e1.m, e1.scopes)
+		e2.onCreate()
+		requireEquals(t, len(tMock.Fatals), 0)
+		requireEquals(t, 1, len(e1.scopes))
+		requireEquals(t, 2, e1.scopes[tMock.Name()].envs)
+
+		fixture := func(e Env) int {
+			return e.CacheResult(func() (*Result, error) {
+				return NewResult(rand.Int()), nil
+			}).(int)
+		}
+		requireEquals(t, fixture(e1), fixture(e2))
+
+		// first teardown keep scope for second env
+		e2.tearDown()
+		requireEquals(t, 1, len(e1.scopes))
+		requireEquals(t, 1, cacheSize(e1.scopes))
+
+		e1.tearDown()
+		requireEquals(t, 0, len(e1.scopes))
+		requireEquals(t, 0, cacheSize(e1.scopes))
 	})
 
 	t.Run("double_env_similar_scope_different_time", func(t *testing.T) {
@@ -178,6 +215,148 @@ func Test_Env_CacheResult(t *testing.T) {
 		})
 		requireEquals(t, 1, tMock.SkipCount)
 	})
+	t.Run("wrapped_skip_reason_logged", func(t *testing.T) {
+		tMock := &internal.TestMock{TestName: "mock"}
+		e := newTestEnv(tMock)
+		runUntilFatal(func() {
+			e.CacheResult(func() (*Result, error) {
+				return nil, fmt.Errorf("%w: test reason", ErrSkipTest)
+			})
+		})
+		requireEquals(t, 1, tMock.SkipCount)
+		requireEquals(t, 1, len(tMock.Logs))
+		requireEquals(t, "fixenv: skip test: test reason", tMock.Logs[0].ResultString)
+	})
+	t.Run("reset", func(t *testing.T) {
+		m := &sync.Mutex{}
+		scopes := make(map[string]*scopeInfo)
+		parentMock := &internal.TestMock{TestName: "parent"}
+		newEnv(parentMock, m, scopes).onCreate()
+
+		resets := 0
+		fixture := func(e Env, scope CacheScope) int {
+			return e.CacheResult(func() (*Result, error) {
+				return &Result{Value: 1, ResultAdditional: ResultAdditional{Reset: func() {
+					resets++
+				}}}, nil
+			}, CacheOptions{Scope: scope}).(int)
+		}
+
+		// fixture of own scope not reset
+		parentEnv := newEnv(parentMock, m, scopes)
+		fixture(parentEnv, ScopeTestAndSubtests)
+
+		subMock := &internal.TestMock{TestName: "parent/sub"}
+		subEnv := newEnv(subMock, m, scopes)
+		subEnv.onCreate()
+		fixture(subEnv, ScopeTestAndSubtests)
+		fixture(subEnv, ScopeTestAndSubtests)
+		fixture(subEnv, ScopeTest)
+		requireEquals(t, 0, resets)
+
+		subMock.CallCleanup()
+		requireEquals(t, 1, resets)
+
+		parentMock.CallCleanup()
+		requireEquals(t, 1, resets)
+	})
+	t.Run("validate", func(t *testing.T) {
+		tMock := &internal.TestMock{TestName: "mock"}
+		e := newTestEnv(tMock)
+
+		created, cleanups := 0, 0
+		healthy := true
+		fixture := func() int {
+			return e.CacheResult(func() (*Result, error) {
+				created++
+				res := NewResultWithCleanup(created, func() {
+					cleanups++
+				})
+				res.Validate = func() error {
+					if healthy {
+						return nil
+					}
+					return errors.New("dead")
+				}
+				return res, nil
+			}).(int)
+		}
+
+		requireEquals(t, 1, fixture())
+		requireEquals(t, 1, fixture())
+
+		healthy = false
+		requireEquals(t, 2, fixture())
+		requireEquals(t, 1, cleanups)
+		requireEquals(t, 1, len(tMock.Logs))
+		requireTrue(t, strings.Contains(tMock.Logs[0].ResultString, "dead"))
+
+		healthy = true
+		requireEquals(t, 2, fixture())
+
+		// stale value must not be cleaned twice
+		tMock.CallCleanup()
+		requireEquals(t, 2, cleanups)
+	})
+	t.Run("max_entries", func(t *testing.T) {
+		tMock := &internal.TestMock{TestName: "mock"}
+		e := newTestEnv(tMock)
+
+		created := 0
+		var cleanups []string
+		fixture := func(name string) string {
+			return e.CacheResult(func() (*Result, error) {
+				created++
+				return NewResultWithCleanup(name, func() {
+					cleanups = append(cleanups, name)
+				}), nil
+			}, CacheOptions{CacheKey: name, MaxEntries: 2}).(string)
+		}
+
+		fixture("a")
+		fixture("b")
+		fixture("a")
+		fixture("c")
+		requireEquals(t, []string{"b"}, cleanups)
+		requireEquals(t, 2, len(e.scopes["mock"].Keys()))
+
+		fixture("a")
+		requireEquals(t, 3, created)
+		fixture("b")
+		requireEquals(t, 4, created)
+		requireEquals(t, []string{"b", "c"}, cleanups)
+
+		tMock.CallCleanup()
+		requireEquals(t, []string{"b", "c", "b", "a"}, cleanups)
+	})
+	t.Run("max_hits", func(t *testing.T) {
+		tMock := &internal.TestMock{TestName: "mock"}
+		e := newTestEnv(tMock)
+
+		created, cleanups := 0, 0
+		fixture := func() int {
+			return e.CacheResult(func() (*Result, error) {
+				created++
+				res := NewResultWithCleanup(created, func() {
+					cleanups++
+				})
+				res.MaxHits = 1
+				return res, nil
+			}).(int)
+		}
+
+		requireEquals(t, 1, fixture())
+		requireEquals(t, 1, fixture())
+		requireEquals(t, 2, fixture())
+		requireEquals(t, 1, cleanups)
+		requireEquals(t, 2, fixture())
+
+		// re-created value has one key in scope
+		requireEquals(t, 1, len(e.scopeInfo(tMock.Name()).Keys()))
+
+		tMock.CallCleanup()
+		requireEquals(t, 2, cleanups)
+	})
 }
 
 func Test_FixtureWrapper(t *testing.T) {
@@ -186,7 +365,7 @@ func Test_FixtureWrapper(t *testing.T) {
 		defer tMock.CallCleanup()
 
 		e := newTestEnv(tMock)
-		key := cacheKey("asd")
+		key := testCacheKey("asd")
 
 		cnt := 0
 		w := e.fixtureCallWrapper(key, func() (res *Result, err error) {
@@ -203,7 +382,7 @@ func Test_FixtureWrapper(t *testing.T) {
 		requireEquals(t, []cacheKey{key}, si.cacheKeys)
 
 		cnt = 0
-		key2 := cacheKey("asd")
+		key2 := testCacheKey("asd")
 		cleanupsLen := len(tMock.Cleanups)
 		w = e.fixtureCallWrapper(key2, func() (res *Result, err error) {
 			cnt++
@@ -222,7 +401,7 @@ func Test_FixtureWrapper(t *testing.T) {
 		e := newTestEnv(tMock)
 
 		tMock.TestName = "mock2"
-		w := e.fixtureCallWrapper("asd", func() (res *Result, err error) {
+		w := e.fixtureCallWrapper(testCacheKey("asd"), func() (res *Result, err error) {
 			return NewResult(nil), nil
 		}, CacheOptions{})
 		runUntilFatal(func() {
@@ -314,7 +493,7 @@ func Test_Env_TearDown(t *testing.T) {
 		e1 := newTestEnv(t1)
 		requireEquals(t, len(e1.scopes), 1)
 		requireEquals(t, len(e1.scopes[makeScopeName(t1.TestName, ScopeTest)].Keys()), 0)
-		requireEquals(t, len(e1.c.store), 0)
+		requireEquals(t, cacheSize(e1.scopes), 0)
 
 		e1.CacheResult(func() (*Result, error) {
 			return NewResult(nil), nil
@@ -324,7 +503,7 @@ func Test_Env_TearDown(t *testing.T) {
 		}, CacheOptions{CacheKey: 2})
 		requireEquals(t, len(e1.scopes), 1)
 		requireEquals(t, len(e1.scopes[makeScopeName(t1.TestName, ScopeTest)].Keys()), 2)
-		requireEquals(t, len(e1.c.store), 2)
+		requireEquals(t, cacheSize(e1.scopes), 2)
 
 		t2 := &internal.TestMock{TestName: "mock2"}
 		// defer t2.callCleanup - direct call e2.tearDown - for test
@@ -333,7 +512,7 @@ func Test_Env_TearDown(t *testing.T) {
 		requireEquals(t, len(e1.scopes), 2)
 		requireEquals(t, len(e1.scopes[makeScopeName(t1.TestName, ScopeTest)].Keys()), 2)
 		requireEquals(t, len(e1.scopes[makeScopeName(t2.TestName, ScopeTest)].Keys()), 0)
-		requireEquals(t, len(e1.c.store), 2)
+		requireEquals(t, cacheSize(e1.scopes), 2)
 
 		e2.CacheResult(func() (*Result, error) {
 			return nil, nil
@@ -342,17 +521,41 @@ func Test_Env_TearDown(t *testing.T) {
 		requireEquals(t, len(e1.scopes), 2)
 		requireEquals(t, len(e1.scopes[makeScopeName(t1.TestName, ScopeTest)].Keys()), 2)
 		requireEquals(t, len(e1.scopes[makeScopeName(t2.TestName, ScopeTest)].Keys()), 1)
-		requireEquals(t, len(e1.c.store), 3)
+		requireEquals(t, cacheSize(e1.scopes), 3)
 
 		// finish first test and tearDown e1
 		e1.tearDown()
 		requireEquals(t, len(e1.scopes), 1)
 		requireEquals(t, len(e1.scopes[makeScopeName(t2.TestName, ScopeTest)].Keys()), 1)
-		requireEquals(t, len(e1.c.store), 1)
+		requireEquals(t, cacheSize(e1.scopes), 1)
 
 		e2.tearDown()
 		requireEquals(t, len(e1.scopes), 0)
-		requireEquals(t, len(e1.c.store), 0)
+		requireEquals(t, cacheSize(e1.scopes), 0)
+	})
+
+	t.Run("use_after_teardown", func(t *testing.T) {
+		tMock := &internal.TestMock{TestName: "mock"}
+		e := newTestEnv(tMock)
+
+		called := 0
+		fixtureAfterTeardown := func(e Env) {
+			e.CacheResult(func() (*Result, error) {
+				called++
+				return NewResult(nil), nil
+			})
+		}
+		fixtureAfterTeardown(e)
+		tMock.CallCleanup()
+		requireEquals(t, 0, len(tMock.Fatals))
+
+		runUntilFatal(func() {
+			fixtureAfterTeardown(e)
+		})
+		requireEquals(t, 1, called)
+		requireEquals(t, 1, len(tMock.Fatals))
+		requireTrue(t, strings.Contains(tMock.Fatals[0].ResultString, `"mock"`))
+		requireTrue(t, strings.Contains(tMock.Fatals[0].ResultString, "Test_Env_TearDown"))
 	})
 
 	t.Run("tearDown on unexisted scope", func(t *testing.T) {
@@ -384,8 +587,16 @@ func Test_MakeCacheKey(t *testing.T) {
 	publicEnvFunc() // external caller
 	noError(t, err)
 
-	expected := cacheKey(`{"func":"github.com/rekby/fixenv.Test_MakeCacheKey","fname":".../env_test.go","scope":0,"scope_name":"asdf","params":222}`)
-	requireJSONEquals(t, string(expected), string(res))
+	requireEquals(t, "github.com/rekby/fixenv.Test_MakeCacheKey", res.fixture.function)
+	requireEquals(t, ".../env_test.go", res.fixture.file)
+	requireEquals(t, ScopeTest, res.scope)
+	requireEquals(t, "asdf", res.scopeName)
+	requireEquals(t, 222, res.params)
+
+	// fixture identity same for every call
+	res2 := res
+	publicEnvFunc()
+	requireTrue(t, res2 == res)
 }
 
 func Test_MakeCacheKeyFromFrame(t *testing.T) {
@@ -396,13 +607,12 @@ func Test_MakeCacheKeyFromFrame(t *testing.T) {
 			File:     "/asd/file_name.go",
 		}, "scope-name", false)
 		noError(t, err)
-		requireJSONEquals(t, `{
-	"scope": 0,
-	"scope_name": "scope-name",
-	"func": "func_name",
-	"fname": "/asd/file_name.go",
-	"params": 123
-}`, string(key))
+		requireEquals(t, cacheKey{
+			fixture:   &fixtureID{function: "func_name", file: "/asd/file_name.go"},
+			scope:     ScopeTest,
+			scopeName: "scope-name",
+			params:    123,
+		}, key)
 	})
 
 	t.Run("test_call", func(t *testing.T) {
@@ -412,13 +622,7 @@ func Test_MakeCacheKeyFromFrame(t *testing.T) {
 			File:     "/asd/file_name.go",
 		}, "scope-name", true)
 		noError(t, err)
-		requireJSONEquals(t, `{
-	"scope": 0,
-	"scope_name": "scope-name",
-	"func": "func_name",
-	"fname": ".../file_name.go",
-	"params": 123
-}`, string(key))
+		requireEquals(t, ".../file_name.go", key.fixture.file)
 	})
 
 	t.Run("no_func_name", func(t *testing.T) {
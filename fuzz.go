@@ -0,0 +1,149 @@
+package fixenv
+
+import "sync"
+
+// FuzzEnv is env of fuzz target, it share fixtures between all inputs of the target.
+// Create it by NewFuzz from testing.F before call f.Fuzz and get env for every input by Input.
+//
+//	func FuzzHandler(f *testing.F) {
+//		fe := fixenv.NewFuzz(f)
+//		f.Fuzz(func(t *testing.T, data []byte) {
+//			e := fe.Input(t)
+//			server := sf.HTTPServer(e) // created once per fuzz target
+//			...
+//		})
+//	}
+type FuzzEnv struct {
+	*EnvT
+
+	input    *fuzzInputT
+	inputEnv *EnvT
+
+	m           sync.Mutex
+	inputResets []func()
+}
+
+// NewFuzz create env for fuzz target.
+// It must be called before f.Fuzz, because testing.F deny register cleanups inside fuzz function.
+// ScopeTest fixtures of the env and inputs envs will be created once per fuzz target and
+// cleaned up after the target finished.
+func NewFuzz(f T) *FuzzEnv {
+	target := &fuzzTargetT{T: f}
+	f.Cleanup(target.runCleanups)
+
+	env := New(target)
+	input := &fuzzInputT{target: f}
+	return &FuzzEnv{
+		EnvT:     env,
+		input:    input,
+		inputEnv: newEnv(input, env.m, env.scopes),
+	}
+}
+
+// Input return env for fuzz input t.
+// Fixtures share cache with fuzz target env, but Fatalf, SkipNow and Logf
+// called from the fixtures use test of current input, or test of fuzz target between inputs.
+// Inputs of one fuzz target must not run in parallel.
+func (e *FuzzEnv) Input(t T) *EnvT {
+	e.input.setCurrent(t)
+	t.Cleanup(e.finishInput)
+	return e.inputEnv
+}
+
+// AddInputReset register f for call after every fuzz input finished.
+// Use it for reset state of shared fixtures between inputs: truncate tables, flush caches, etc.
+func (e *FuzzEnv) AddInputReset(f func()) {
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	e.inputResets = append(e.inputResets, f)
+}
+
+func (e *FuzzEnv) finishInput() {
+	e.m.Lock()
+	resets := make([]func(), len(e.inputResets))
+	copy(resets, e.inputResets)
+	e.m.Unlock()
+
+	for _, f := range resets {
+		f()
+	}
+	e.input.setCurrent(nil)
+}
+
+// fuzzTargetT collect cleanups of fuzz target scope, because testing.F panics
+// if Cleanup called inside fuzz function.
+type fuzzTargetT struct {
+	T
+
+	m        sync.Mutex
+	cleanups []func()
+}
+
+func (t *fuzzTargetT) Cleanup(f func()) {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	t.cleanups = append(t.cleanups, f)
+}
+
+func (t *fuzzTargetT) runCleanups() {
+	t.m.Lock()
+	cleanups := t.cleanups
+	t.cleanups = nil
+	t.m.Unlock()
+
+	for i := len(cleanups) - 1; i >= 0; i-- {
+		cleanups[i]()
+	}
+}
+
+// fuzzInputT forward calls to test of current fuzz input or to fuzz target between inputs.
+// Name is always name of fuzz target, then fixtures of inputs resolve to scope of the target.
+type fuzzInputT struct {
+	target T
+
+	m       sync.Mutex
+	current T
+}
+
+func (t *fuzzInputT) setCurrent(current T) {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	t.current = current
+}
+
+func (t *fuzzInputT) active() T {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	if t.current == nil {
+		return t.target
+	}
+	return t.current
+}
+
+func (t *fuzzInputT) Cleanup(f func()) {
+	t.active().Cleanup(f)
+}
+
+func (t *fuzzInputT) Fatalf(format string, args ...interface{}) {
+	t.active().Fatalf(format, args...)
+}
+
+func (t *fuzzInputT) Logf(format string, args ...interface{}) {
+	t.active().Logf(format, args...)
+}
+
+func (t *fuzzInputT) Name() string {
+	return t.target.Name()
+}
+
+func (t *fuzzInputT) SkipNow() {
+	t.active().SkipNow()
+}
+
+func (t *fuzzInputT) Skipped() bool {
+	return t.active().Skipped()
+}
@@ -0,0 +1,31 @@
+//go:build go1.18
+// +build go1.18
+
+package fixenv
+
+import (
+	"testing"
+)
+
+var fuzzFixtureCalls int
+
+func FuzzFuzzEnv(f *testing.F) {
+	fuzzFixtureCalls = 0
+	fe := NewFuzz(f)
+	fixture := func(e Env) int {
+		return CacheResult(e, func() (*GenericResult[int], error) {
+			fuzzFixtureCalls++
+			return NewGenericResultWithCleanup(fuzzFixtureCalls, func() {}), nil
+		})
+	}
+
+	f.Add(1)
+	f.Add(2)
+	f.Add(3)
+	f.Fuzz(func(t *testing.T, _ int) {
+		e := fe.Input(t)
+		if val := fixture(e); val != 1 {
+			t.Fatalf("fixture must be created once per fuzz target, got: %v", val)
+		}
+	})
+}
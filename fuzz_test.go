@@ -0,0 +1,60 @@
+package fixenv
+
+import (
+	"testing"
+
+	"github.com/rekby/fixenv/internal"
+)
+
+func TestFuzzEnv(t *testing.T) {
+	scopesBefore := len(globalScopeInfo)
+
+	target := &internal.TestMock{TestName: "FuzzTarget"}
+	fe := NewFuzz(target)
+	requireEquals(t, 1, len(target.Cleanups))
+
+	fixtureCalls := 0
+	cleanupCalls := 0
+	fixture := func(e Env) int {
+		return e.CacheResult(func() (*Result, error) {
+			fixtureCalls++
+			return NewResultWithCleanup(fixtureCalls, func() {
+				cleanupCalls++
+				e.T().Logf("cleanup")
+			}), nil
+		}).(int)
+	}
+
+	resetCalls := 0
+	fe.AddInputReset(func() {
+		resetCalls++
+	})
+
+	var inputs []*internal.TestMock
+	for _, name := range []string{"FuzzTarget/seed#0", "FuzzTarget/seed#1"} {
+		input := &internal.TestMock{TestName: name}
+		inputs = append(inputs, input)
+
+		e := fe.Input(input)
+		requireEquals(t, 1, fixture(e))
+		requireEquals(t, input, fe.input.active())
+		input.CallCleanup()
+	}
+	requireEquals(t, 1, fixtureCalls)
+	requireEquals(t, 2, resetCalls)
+	requireEquals(t, 0, cleanupCalls)
+	requireEquals(t, target, fe.input.active())
+
+	// target env share fixtures with inputs
+	requireEquals(t, 1, fixture(fe))
+
+	target.CallCleanup()
+	requireEquals(t, 1, cleanupCalls)
+	requireEquals(t, scopesBefore, len(globalScopeInfo))
+
+	// cleanup log go to fuzz target, because inputs finished
+	requireEquals(t, 1, len(target.Logs))
+	for _, input := range inputs {
+		requireEquals(t, 0, len(input.Logs))
+	}
+}
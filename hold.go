@@ -0,0 +1,95 @@
+package fixenv
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"regexp"
+	"sync"
+)
+
+// HoldEnvName is name of environment variable with regexp of test names for hold before teardown.
+// Use TestMain for hold package scope: FIXENV_HOLD=^TestMain$
+// It is same as CreateMainTestEnvOpts.Hold.
+const HoldEnvName = "FIXENV_HOLD"
+
+var (
+	holdMutex   sync.Mutex
+	holdPattern *regexp.Regexp
+
+	// holdOutput and holdWait replaced by tests
+	holdOutput io.Writer = os.Stderr
+	holdWait             = waitEnterOrInterrupt
+)
+
+func setHoldPattern(re *regexp.Regexp) {
+	holdMutex.Lock()
+	defer holdMutex.Unlock()
+
+	holdPattern = re
+}
+
+// needHold return true if scope with the name must wait before teardown
+func needHold(scopeName string) bool {
+	holdMutex.Lock()
+	re := holdPattern
+	holdMutex.Unlock()
+
+	if re == nil {
+		envVal := os.Getenv(HoldEnvName)
+		if envVal == "" {
+			return false
+		}
+		var err error
+		re, err = regexp.Compile(envVal)
+		if err != nil {
+			log.Printf("fixenv: failed to compile %v regexp %q: %v", HoldEnvName, envVal, err)
+			return false
+		}
+	}
+	return re.MatchString(scopeName)
+}
+
+// hold print live fixtures of the scope and wait for user before run cleanups
+func (e *EnvT) hold(si *scopeInfo) {
+	scopeName := si.t.Name()
+	if !needHold(scopeName) {
+		return
+	}
+
+	_, _ = fmt.Fprintf(holdOutput, "fixenv: hold before teardown scope %q, live fixtures:\n", scopeName)
+	for _, key := range si.Keys() {
+		val, ok := si.c.get(key)
+		switch {
+		case !ok:
+			continue
+		case val.err != nil:
+			_, _ = fmt.Fprintf(holdOutput, "  %v\n    error: %v\n", key, val.err)
+		default:
+			_, _ = fmt.Fprintf(holdOutput, "  %v\n    value: %v\n", key, val.res.Value)
+		}
+	}
+	_, _ = fmt.Fprintln(holdOutput, "fixenv: press Enter or Ctrl+C for continue teardown")
+	holdWait()
+}
+
+func waitEnterOrInterrupt() {
+	done := make(chan struct{}, 1)
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	go func() {
+		_, _ = bufio.NewReader(os.Stdin).ReadString('\n')
+		done <- struct{}{}
+	}()
+
+	select {
+	case <-done:
+	case <-interrupt:
+	}
+}
@@ -0,0 +1,87 @@
+package fixenv
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/rekby/fixenv/internal"
+)
+
+func TestHold(t *testing.T) {
+	var out bytes.Buffer
+	waitCalled := 0
+	oldOutput, oldWait := holdOutput, holdWait
+	holdOutput = &out
+	holdWait = func() {
+		waitCalled++
+	}
+	defer func() {
+		holdOutput, holdWait = oldOutput, oldWait
+		setHoldPattern(nil)
+	}()
+
+	runEnv := func(testName string) {
+		tMock := &internal.TestMock{TestName: testName}
+		e := newTestEnv(tMock)
+		for _, name := range []string{"first-value", "second-value"} {
+			e.CacheResult(func() (*Result, error) {
+				return NewResultWithCleanup(name, func() {}), nil
+			}, CacheOptions{CacheKey: name})
+		}
+		tMock.CallCleanup()
+	}
+
+	t.Run("matched", func(t *testing.T) {
+		out.Reset()
+		waitCalled = 0
+		setHoldPattern(regexp.MustCompile("^TestHold$"))
+
+		runEnv("TestHold")
+		requireEquals(t, 1, waitCalled)
+		requireTrue(t, strings.Contains(out.String(), "first-value"))
+		requireTrue(t, strings.Contains(out.String(), "second-value"))
+	})
+
+	t.Run("without_cleanups", func(t *testing.T) {
+		out.Reset()
+		waitCalled = 0
+		setHoldPattern(regexp.MustCompile("^TestHold$"))
+
+		tMock := &internal.TestMock{TestName: "TestHold"}
+		e := newTestEnv(tMock)
+		e.CacheResult(func() (*Result, error) {
+			return NewResult("dsn-value"), nil
+		})
+		tMock.CallCleanup()
+		requireEquals(t, 1, waitCalled)
+		requireTrue(t, strings.Contains(out.String(), "dsn-value"))
+	})
+
+	t.Run("not_matched", func(t *testing.T) {
+		out.Reset()
+		waitCalled = 0
+		setHoldPattern(regexp.MustCompile("^TestHold$"))
+
+		runEnv("TestOther")
+		requireEquals(t, 0, waitCalled)
+		requireEquals(t, 0, out.Len())
+	})
+
+	t.Run("env", func(t *testing.T) {
+		out.Reset()
+		waitCalled = 0
+		setHoldPattern(nil)
+		setenvForTest(t, HoldEnvName, "Env")
+
+		runEnv("TestHoldEnv")
+		requireEquals(t, 1, waitCalled)
+	})
+
+	t.Run("env_bad_regexp", func(t *testing.T) {
+		setHoldPattern(nil)
+		setenvForTest(t, HoldEnvName, "(")
+		requireFalse(t, needHold("("))
+	})
+}
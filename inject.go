@@ -0,0 +1,216 @@
+package fixenv
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// InjectTagName is name of struct tag for Inject: `fixenv:"name,key=value"`.
+// name select fixture, registered with the name, key passed to fixture as key param.
+// Use `fixenv:"-"` for skip field.
+const InjectTagName = "fixenv"
+
+var (
+	// DefaultRegistry used by Register and Inject functions
+	DefaultRegistry = NewRegistry()
+
+	envType    = reflect.TypeOf((*Env)(nil)).Elem()
+	stringType = reflect.TypeOf("")
+)
+
+// Registry is registry of fixtures by result type and name.
+// It is used for inject fixture values to struct fields.
+type Registry struct {
+	id int64
+
+	m           sync.RWMutex
+	fixtures    map[registryKey]reflect.Value
+	lastFixture int64
+}
+
+type registryKey struct {
+	t    reflect.Type
+	name string
+}
+
+var lastRegistryID int64
+
+// NewRegistry create empty registry
+func NewRegistry() *Registry {
+	return &Registry{
+		id:       atomic.AddInt64(&lastRegistryID, 1),
+		fixtures: make(map[registryKey]reflect.Value),
+	}
+}
+
+// Register fixture in DefaultRegistry, see Registry.Register
+func Register(name string, fixture interface{}) {
+	DefaultRegistry.Register(name, fixture)
+}
+
+// Inject fill struct fields from fixtures of DefaultRegistry, see Registry.Inject
+func Inject(env Env, target interface{}) {
+	if ht, ok := env.T().(helperT); ok {
+		ht.Helper()
+	}
+	DefaultRegistry.Inject(env, target)
+}
+
+// Register fixture by type of result and name, name may be empty.
+// fixture must be function with signature func(e fixenv.Env) T or func(e fixenv.Env, key string) T.
+// The fixture is usual fixture function, it calls CacheResult with own scope and cache key.
+// Register panics if fixture has other signature or fixture for same type and name registered already.
+func (r *Registry) Register(name string, fixture interface{}) {
+	f := reflect.ValueOf(fixture)
+	resType, err := fixtureResultType(f)
+	if err != nil {
+		panic(fmt.Errorf("fixenv: failed to register fixture %q: %w", name, err))
+	}
+	r.add(registryKey{t: resType, name: name}, f)
+}
+
+// Inject fill exported fields of struct, pointed by target, from registered fixtures.
+// Fixture selected by field type and name from struct tag (see InjectTagName).
+// Inject fail test if fixture for any field not found.
+func (r *Registry) Inject(env Env, target interface{}) {
+	if ht, ok := env.T().(helperT); ok {
+		ht.Helper()
+	}
+
+	if err := r.inject(env, target); err != nil {
+		env.T().Fatalf("fixenv: failed to inject fixtures: %v", err)
+	}
+}
+
+func (r *Registry) add(key registryKey, f reflect.Value) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	if _, ok := r.fixtures[key]; ok {
+		panic(fmt.Errorf("fixenv: fixture for type %v with name %q registered already", key.t, key.name))
+	}
+	r.fixtures[key] = f
+}
+
+// set fixture for key, replace previous fixture if exists
+func (r *Registry) set(key registryKey, f reflect.Value) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	r.fixtures[key] = f
+}
+
+// nextFixtureID return unique id of fixture in the registry
+func (r *Registry) nextFixtureID() int64 {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	r.lastFixture++
+	return r.lastFixture
+}
+
+func (r *Registry) get(key registryKey) (reflect.Value, bool) {
+	r.m.RLock()
+	defer r.m.RUnlock()
+
+	f, ok := r.fixtures[key]
+	return f, ok
+}
+
+func (r *Registry) inject(env Env, target interface{}) error {
+	targetVal := reflect.ValueOf(target)
+	if targetVal.Kind() != reflect.Ptr || targetVal.IsNil() || targetVal.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("target must be not nil pointer to struct, got: %T", target)
+	}
+
+	structVal := targetVal.Elem()
+	structType := structVal.Type()
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if field.PkgPath != "" {
+			// unexported
+			continue
+		}
+
+		tag, err := parseInjectTag(field.Tag.Get(InjectTagName))
+		if err != nil {
+			return fmt.Errorf("field %v: %w", field.Name, err)
+		}
+		if tag.skip {
+			continue
+		}
+
+		val, err := r.call(env, registryKey{t: field.Type, name: tag.name}, tag)
+		if err != nil {
+			return fmt.Errorf("field %v: %w", field.Name, err)
+		}
+		structVal.Field(i).Set(val)
+	}
+	return nil
+}
+
+func (r *Registry) call(env Env, key registryKey, tag injectTag) (reflect.Value, error) {
+	f, ok := r.get(key)
+	if !ok {
+		return reflect.Value{}, fmt.Errorf("fixture for type %v with name %q not registered", key.t, key.name)
+	}
+
+	args := []reflect.Value{reflect.ValueOf(&env).Elem()}
+	switch {
+	case f.Type().NumIn() == 2:
+		args = append(args, reflect.ValueOf(tag.key))
+	case tag.hasKey:
+		return reflect.Value{}, fmt.Errorf("fixture for type %v with name %q has no key param", key.t, key.name)
+	}
+	return f.Call(args)[0], nil
+}
+
+func fixtureResultType(f reflect.Value) (reflect.Type, error) {
+	if f.Kind() != reflect.Func || f.IsNil() {
+		return nil, errors.New("fixture must be function")
+	}
+
+	ft := f.Type()
+	switch {
+	case ft.NumOut() != 1:
+		return nil, fmt.Errorf("fixture must return one value, got: %v", ft)
+	case ft.NumIn() != 1 && ft.NumIn() != 2, ft.IsVariadic():
+		return nil, fmt.Errorf("fixture must have signature func(fixenv.Env) T or func(fixenv.Env, string) T, got: %v", ft)
+	case ft.In(0) != envType:
+		return nil, fmt.Errorf("first param of fixture must be fixenv.Env, got: %v", ft)
+	case ft.NumIn() == 2 && ft.In(1) != stringType:
+		return nil, fmt.Errorf("second param of fixture must be string key, got: %v", ft)
+	default:
+		return ft.Out(0), nil
+	}
+}
+
+type injectTag struct {
+	skip   bool
+	name   string
+	key    string
+	hasKey bool
+}
+
+func parseInjectTag(tag string) (injectTag, error) {
+	if tag == "-" {
+		return injectTag{skip: true}, nil
+	}
+
+	parts := strings.Split(tag, ",")
+	res := injectTag{name: parts[0]}
+	for _, part := range parts[1:] {
+		switch {
+		case strings.HasPrefix(part, "key="):
+			res.key = strings.TrimPrefix(part, "key=")
+			res.hasKey = true
+		default:
+			return injectTag{}, fmt.Errorf("unexpected option %q in tag %q", part, tag)
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,122 @@
+package fixenv
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rekby/fixenv/internal"
+)
+
+type injectTestServer struct {
+	name string
+}
+
+func TestRegistry_Inject(t *testing.T) {
+	r := NewRegistry()
+
+	serverCalls := 0
+	r.Register("", func(e Env) *injectTestServer {
+		return e.CacheResult(func() (*Result, error) {
+			serverCalls++
+			return NewResult(&injectTestServer{name: "default"}), nil
+		}).(*injectTestServer)
+	})
+	r.Register("named", func(e Env, key string) *injectTestServer {
+		return e.CacheResult(func() (*Result, error) {
+			return NewResult(&injectTestServer{name: "named-" + key}), nil
+		}, CacheOptions{CacheKey: key}).(*injectTestServer)
+	})
+	r.Register("", func(e Env, key string) string {
+		return "string-" + key
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		e := newTestEnv(t)
+
+		var deps struct {
+			Server      *injectTestServer
+			Server2     *injectTestServer
+			NamedServer *injectTestServer `fixenv:"named,key=alice"`
+			Str         string
+			Skipped     int `fixenv:"-"`
+			unexported  int
+		}
+		r.Inject(e, &deps)
+
+		requireEquals(t, "default", deps.Server.name)
+		requireTrue(t, deps.Server == deps.Server2)
+		requireEquals(t, 1, serverCalls)
+		requireEquals(t, "named-alice", deps.NamedServer.name)
+		requireEquals(t, "string-", deps.Str)
+		requireEquals(t, 0, deps.Skipped)
+		requireEquals(t, 0, deps.unexported)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		table := []struct {
+			name   string
+			target interface{}
+			err    string
+		}{
+			{name: "not_pointer", target: struct{}{}, err: "pointer to struct"},
+			{name: "not_struct", target: new(int), err: "pointer to struct"},
+			{name: "not_registered", target: &struct{ V int }{}, err: "not registered"},
+			{name: "bad_tag", target: &struct {
+				V string `fixenv:",asd"`
+			}{}, err: "unexpected option"},
+			{name: "key_not_supported", target: &struct {
+				V *injectTestServer `fixenv:",key=asd"`
+			}{}, err: "has no key param"},
+		}
+
+		for _, test := range table {
+			t.Run(test.name, func(t *testing.T) {
+				tMock := &internal.TestMock{TestName: "mock", SkipGoexit: true}
+				e := newTestEnv(tMock)
+				r.Inject(e, test.target)
+				requireEquals(t, 1, len(tMock.Fatals))
+				requireTrue(t, strings.Contains(tMock.Fatals[0].ResultString, test.err))
+			})
+		}
+	})
+}
+
+func TestRegistry_Register(t *testing.T) {
+	r := NewRegistry()
+	r.Register("", func(e Env) int { return 1 })
+	requirePanic(t, func() {
+		r.Register("", func(e Env) int { return 2 })
+	})
+
+	for _, fixture := range []interface{}{
+		nil,
+		1,
+		func() int { return 1 },
+		func(e Env) {},
+		func(e Env) (int, int) { return 1, 2 },
+		func(i int) int { return i },
+		func(e Env, i int) int { return i },
+		func(e Env, keys ...string) int { return 1 },
+	} {
+		requirePanic(t, func() {
+			r.Register("bad", fixture)
+		})
+	}
+}
+
+func TestInjectDefaultRegistry(t *testing.T) {
+	oldRegistry := DefaultRegistry
+	DefaultRegistry = NewRegistry()
+	defer func() {
+		DefaultRegistry = oldRegistry
+	}()
+
+	type injectDefaultType struct{ v int }
+	Register("", func(e Env) injectDefaultType {
+		return injectDefaultType{v: 1}
+	})
+
+	var deps struct{ V injectDefaultType }
+	Inject(newTestEnv(t), &deps)
+	requireEquals(t, 1, deps.V.v)
+}
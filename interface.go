@@ -1,6 +1,10 @@
 package fixenv
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"time"
+)
 
 // Env - fixture cache engine.
 // Env interface described TEnv method and need for easy reuse different Envs with
@@ -24,7 +28,10 @@ var (
 	// as usual result/error cache.
 	//
 	// Use special error instead of detect of test.SkipNow() need for prevent run fixture in separate goroutine for
-	// skip detecting
+	// skip detecting.
+	//
+	// The error may be wrapped for describe skip reason: fmt.Errorf("%w: service not configured", ErrSkipTest),
+	// the reason will be logged to test before skip.
 	ErrSkipTest = errors.New("skip test")
 )
 
@@ -36,6 +43,10 @@ type CacheScope int
 const (
 	// ScopeTest mean fixture function with same parameters called once per every test and subtests. Default value.
 	// Second and more calls will use cached value.
+	// For benchmarks the scope is one call of benchmark function: testing.B calls the function
+	// some times with growing b.N and run cleanups after every call, so fixtures will be created again.
+	// Use ScopePackage for share fixture between the calls, or create env in parent benchmark
+	// and use ScopeTestAndSubtests from sub-benchmarks.
 	ScopeTest CacheScope = iota
 
 	// ScopePackage mean fixture function with same parameters called once per package
@@ -46,11 +57,28 @@ const (
 	ScopeTestAndSubtests
 )
 
+func (s CacheScope) String() string {
+	switch s {
+	case ScopeTest:
+		return "ScopeTest"
+	case ScopePackage:
+		return "ScopePackage"
+	case ScopeTestAndSubtests:
+		return "ScopeTestAndSubtests"
+	default:
+		return fmt.Sprintf("CacheScope(%d)", int(s))
+	}
+}
+
 // FixtureCleanupFunc - callback function for cleanup after
 // fixture value out from lifetime scope
 // it called exactly once for every succesully call fixture
 type FixtureCleanupFunc func()
 
+// FixtureResetFunc - callback function for reset state of fixture value
+// at the end of every test, which used the value from wider scope
+type FixtureResetFunc func()
+
 // FixtureFunction - callback function with structured result
 // the function can return ErrSkipTest error for skip the test
 type FixtureFunction func() (*Result, error)
@@ -63,6 +91,34 @@ type Result struct {
 
 type ResultAdditional struct {
 	Cleanup FixtureCleanupFunc
+
+	// AlwaysCleanup force call Cleanup even for failed tests in keep on failure mode.
+	// Use it for resources, which must be released always.
+	// See KeepOnFailureEnvName and CreateMainTestEnvOpts.KeepOnFailure.
+	AlwaysCleanup bool
+
+	// Reset called at the end of every test, which got the value from wider scope than the test scope.
+	// For example ScopePackage db fixture may truncate tables after every test.
+	// Reset called after cleanups of the test fixtures, one time per test.
+	Reset FixtureResetFunc
+
+	// Validate check health of cached value before reuse, it is optional.
+	// If Validate return error - the stale value cleaned up and fixture called again.
+	// Use it for values of wide scope, which may die partway through a run: connections, subprocesses, etc.
+	Validate func() error
+
+	// ValidateInterval is min interval between validations of the value.
+	// Zero value mean validate on every cache hit.
+	ValidateInterval time.Duration
+
+	// MaxAge is max age of cached value, zero mean unlimited.
+	// Next call of fixture after the age cleanup the value and create new one.
+	// For example for short-lived auth tokens.
+	MaxAge time.Duration
+
+	// MaxHits is max count of returns value from cache, zero mean unlimited.
+	// Next call of fixture after the limit cleanup the value and create new one.
+	MaxHits int
 }
 
 func NewResult(res interface{}) *Result {
@@ -77,9 +133,25 @@ type CacheOptions struct {
 	// Scope for cache result
 	Scope CacheScope
 
-	// Key for cache results, must be json serializable value
+	// Key for cache results, must be json serializable value, implement CacheKeyer
+	// or has cache key function, registered by RegisterCacheKeyFunc.
+	// Values with unexported fields, which lost in json, fail the test instead of share result.
 	CacheKey interface{}
 
+	// KeepBenchmarkTimer disable stop timer of benchmark while fixture function executed.
+	// By default, if test is *testing.B, fixture setup is not counted in benchmark result.
+	KeepBenchmarkTimer bool
+
+	// CloneOnRead return deep copy of cached value on every call, then tests can't change shared value.
+	// Value cloned by function, registered by RegisterCloneFunc, by Cloner interface or copied by reflection.
+	// See CheckMutationEnvName for find tests, which change shared values.
+	CloneOnRead bool
+
+	// MaxEntries is max count of live values of the fixture in one scope with different CacheKey,
+	// zero mean unlimited. Least recently used values over the limit cleaned up and
+	// will be created again by next call with same CacheKey.
+	MaxEntries int
+
 	additionlSkipExternalCalls int
 }
 
@@ -23,6 +23,10 @@ type TestMock struct {
 	Logs      []FormatCall
 	Fatals    []FormatCall
 	SkipCount int
+	IsFailed  bool
+
+	// HelperCount is count of Helper calls
+	HelperCount int
 }
 
 func (t *TestMock) CallCleanup() {
@@ -38,6 +42,20 @@ func (t *TestMock) Cleanup(f func()) {
 	t.Cleanups = append(t.Cleanups, f)
 }
 
+func (t *TestMock) Fail() {
+	t.M.Lock()
+	defer t.M.Unlock()
+
+	t.IsFailed = true
+}
+
+func (t *TestMock) Failed() bool {
+	t.M.Lock()
+	defer t.M.Unlock()
+
+	return t.IsFailed || len(t.Fatals) > 0
+}
+
 func (t *TestMock) Fatalf(format string, args ...interface{}) {
 	t.M.Lock()
 	defer t.M.Unlock()
@@ -53,6 +71,13 @@ func (t *TestMock) Fatalf(format string, args ...interface{}) {
 	}
 }
 
+func (t *TestMock) Helper() {
+	t.M.Lock()
+	defer t.M.Unlock()
+
+	t.HelperCount++
+}
+
 func (t *TestMock) Logf(format string, args ...interface{}) {
 	t.M.Lock()
 	defer t.M.Unlock()
@@ -18,6 +18,35 @@ func TestTestMock_CallCleanup(t *testing.T) {
 	}
 }
 
+func TestTestMock_Failed(t *testing.T) {
+	t.Run("Fail", func(t *testing.T) {
+		tm := &TestMock{}
+		if tm.Failed() {
+			t.Fatal()
+		}
+		tm.Fail()
+		if !tm.Failed() {
+			t.Fatal()
+		}
+	})
+	t.Run("Fatal", func(t *testing.T) {
+		tm := &TestMock{SkipGoexit: true}
+		tm.Fatalf("asd")
+		if !tm.Failed() {
+			t.Fatal()
+		}
+	})
+}
+
+func TestTestMock_Helper(t *testing.T) {
+	tm := &TestMock{}
+	tm.Helper()
+	tm.Helper()
+	if tm.HelperCount != 2 {
+		t.Fatal(tm.HelperCount)
+	}
+}
+
 func TestTestMock_Fatalf(t *testing.T) {
 	t.Run("SkipExit", func(t *testing.T) {
 		tm := &TestMock{
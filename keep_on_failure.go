@@ -0,0 +1,31 @@
+package fixenv
+
+import (
+	"os"
+	"strconv"
+	"sync/atomic"
+)
+
+// KeepOnFailureEnvName is name of environment variable, which enable keep fixtures
+// of failed tests for post-mortem debug: FIXENV_KEEP_ON_FAILURE=1
+// It is same as CreateMainTestEnvOpts.KeepOnFailure.
+const KeepOnFailureEnvName = "FIXENV_KEEP_ON_FAILURE"
+
+// keepOnFailureOpt is non zero if keep on failure enabled from CreateMainTestEnvOpts
+var keepOnFailureOpt int32
+
+func setKeepOnFailure(enabled bool) {
+	var val int32
+	if enabled {
+		val = 1
+	}
+	atomic.StoreInt32(&keepOnFailureOpt, val)
+}
+
+func keepOnFailureEnabled() bool {
+	if atomic.LoadInt32(&keepOnFailureOpt) != 0 {
+		return true
+	}
+	val, err := strconv.ParseBool(os.Getenv(KeepOnFailureEnvName))
+	return err == nil && val
+}
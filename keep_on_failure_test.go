@@ -0,0 +1,92 @@
+package fixenv
+
+import (
+	"testing"
+
+	"github.com/rekby/fixenv/internal"
+)
+
+func TestKeepOnFailure(t *testing.T) {
+	newFixture := func(e Env, cleanupCalled *int, always bool) {
+		e.CacheResult(func() (*Result, error) {
+			res := NewResultWithCleanup("value", func() {
+				*cleanupCalled++
+			})
+			res.AlwaysCleanup = always
+			return res, nil
+		})
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		setKeepOnFailure(false)
+		tMock := &internal.TestMock{TestName: "mock"}
+		e := newTestEnv(tMock)
+
+		cleanupCalled := 0
+		newFixture(e, &cleanupCalled, false)
+		tMock.Fail()
+		tMock.CallCleanup()
+		requireEquals(t, 1, cleanupCalled)
+	})
+
+	t.Run("enabled_success_test", func(t *testing.T) {
+		setKeepOnFailure(true)
+		defer setKeepOnFailure(false)
+
+		tMock := &internal.TestMock{TestName: "mock"}
+		e := newTestEnv(tMock)
+
+		cleanupCalled := 0
+		newFixture(e, &cleanupCalled, false)
+		tMock.CallCleanup()
+		requireEquals(t, 1, cleanupCalled)
+	})
+
+	t.Run("enabled_failed_test", func(t *testing.T) {
+		setKeepOnFailure(true)
+		defer setKeepOnFailure(false)
+
+		tMock := &internal.TestMock{TestName: "mock"}
+		e := newTestEnv(tMock)
+
+		cleanupCalled := 0
+		newFixture(e, &cleanupCalled, false)
+		tMock.Fail()
+		tMock.CallCleanup()
+		requireEquals(t, 0, cleanupCalled)
+		requireEquals(t, 1, len(tMock.Logs))
+	})
+
+	t.Run("always_cleanup", func(t *testing.T) {
+		setKeepOnFailure(true)
+		defer setKeepOnFailure(false)
+
+		tMock := &internal.TestMock{TestName: "mock"}
+		e := newTestEnv(tMock)
+
+		cleanupCalled := 0
+		newFixture(e, &cleanupCalled, true)
+		tMock.Fail()
+		tMock.CallCleanup()
+		requireEquals(t, 1, cleanupCalled)
+	})
+
+	t.Run("env_var", func(t *testing.T) {
+		setKeepOnFailure(false)
+		setenvForTest(t, KeepOnFailureEnvName, "1")
+
+		tMock := &internal.TestMock{TestName: "mock"}
+		e := newTestEnv(tMock)
+
+		cleanupCalled := 0
+		newFixture(e, &cleanupCalled, false)
+		tMock.Fail()
+		tMock.CallCleanup()
+		requireEquals(t, 0, cleanupCalled)
+	})
+
+	t.Run("virtual_test_failed", func(t *testing.T) {
+		requireFalse(t, isFailed(&virtualTest{}))
+		requireTrue(t, isFailed(&virtualTest{failed: true}))
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"regexp"
 	"sync"
 )
 
@@ -35,6 +36,28 @@ type CreateMainTestEnvOpts struct {
 	// other goroutines created during the test. Calling SkipNow does not stop
 	// those other goroutines.
 	SkipNow SkipNowFunction
+
+	// KeepOnFailure skip fixture cleanups for failed tests (and for package scope if any test failed)
+	// and log values of kept fixtures. It helps to inspect temp dirs, databases and other
+	// resources after test failed.
+	// It can be enabled by environment variable too, see KeepOnFailureEnvName.
+	KeepOnFailure bool
+
+	// Hold is regexp of test names, fixenv print live fixture values and wait for Enter or SIGINT
+	// before run fixture cleanups of matched tests. Use regexp "^TestMain$" for hold package scope.
+	// It helps to inspect running servers, temp dirs, databases interactively.
+	// It can be set by environment variable too, see HoldEnvName.
+	Hold *regexp.Regexp
+
+	// ScopeCheck is reaction on scope violation: fixture with wide scope depends on fixture
+	// with narrower scope while initialize. Default is fail.
+	// It can be set by environment variable too, see ScopeCheckEnvName.
+	ScopeCheck ScopeCheckMode
+
+	// CheckMutation enable check, that tests don't change values of fixtures with CacheOptions.CloneOnRead.
+	// In the mode fixtures return shared value without clone and fail test, which changed the value.
+	// It can be enabled by environment variable too, see CheckMutationEnvName.
+	CheckMutation bool
 }
 
 // packageLevelVirtualTest now used for tests only
@@ -50,6 +73,18 @@ func CreateMainTestEnv(opts *CreateMainTestEnvOpts) (env *EnvT, tearDown func())
 	lastPackageLevelVirtualTest = packageLevelVirtualTest
 	globalMutex.Unlock()
 
+	if opts != nil {
+		setKeepOnFailure(opts.KeepOnFailure)
+		setHoldPattern(opts.Hold)
+		setScopeCheck(opts.ScopeCheck)
+		setCheckMutation(opts.CheckMutation)
+	} else {
+		setKeepOnFailure(false)
+		setHoldPattern(nil)
+		setScopeCheck(ScopeCheckFail)
+		setCheckMutation(false)
+	}
+
 	env = New(packageLevelVirtualTest) // register global test for env
 	return env, packageLevelVirtualTest.cleanup
 }
@@ -73,9 +108,14 @@ func RunTests(m RunTestsI, opts ...CreateMainTestEnvOpts) int {
 		panic(errTooManyOptionalArgs)
 	}
 
-	_, cancel := CreateMainTestEnv(options)
+	env, cancel := CreateMainTestEnv(options)
 	defer cancel()
-	return m.Run()
+
+	code := m.Run()
+	if code != 0 {
+		env.t.(*virtualTest).Fail()
+	}
+	return code
 }
 
 type RunTestsI interface {
@@ -86,11 +126,14 @@ type RunTestsI interface {
 // virtualTest implement T interface for global env scope
 type virtualTest struct {
 	m       sync.Mutex
+	name    string
 	fatalf  FatalfFunction
+	logf    LogfFunction
 	skipNow SkipNowFunction
 
 	cleanups []func()
 	skipped  bool
+	failed   bool
 }
 
 func newVirtualTest(opts *CreateMainTestEnvOpts) *virtualTest {
@@ -98,7 +141,9 @@ func newVirtualTest(opts *CreateMainTestEnvOpts) *virtualTest {
 		opts = &CreateMainTestEnvOpts{}
 	}
 	t := &virtualTest{
+		name:    packageScopeName,
 		fatalf:  opts.Fatalf,
+		logf:    log.Printf,
 		skipNow: opts.SkipNow,
 	}
 
@@ -124,16 +169,32 @@ func (t *virtualTest) Cleanup(f func()) {
 	t.cleanups = append(t.cleanups, f)
 }
 
+// Fail mark package scope as failed
+func (t *virtualTest) Fail() {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	t.failed = true
+}
+
+// Failed report package scope failed
+func (t *virtualTest) Failed() bool {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	return t.failed
+}
+
 func (t *virtualTest) Fatalf(format string, args ...interface{}) {
 	t.fatalf(format, args...)
 }
 
 func (t *virtualTest) Logf(format string, args ...interface{}) {
-	log.Printf(format, args...)
+	t.logf(format, args...)
 }
 
 func (t *virtualTest) Name() string {
-	return packageScopeName
+	return t.name
 }
 
 func (t *virtualTest) SkipNow() {
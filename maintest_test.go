@@ -123,6 +123,12 @@ func TestRunTests(t *testing.T) {
 		}
 		cleanGlobalState()
 	})
+	t.Run("failed", func(t *testing.T) {
+		m := &mTestsMock{returnCode: 1}
+		RunTests(m)
+		requireTrue(t, lastPackageLevelVirtualTest.Failed())
+		cleanGlobalState()
+	})
 	t.Run("with two options", func(t *testing.T) {
 		defer func() {
 			cleanGlobalState()
@@ -0,0 +1,214 @@
+//go:build go1.18
+// +build go1.18
+
+package fixenv
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// PoolOptions is options of resource pool
+type PoolOptions[T any] struct {
+	// Size is max count of instances in the pool, must be greater then 0.
+	Size int
+
+	// Create new instance of the pool. It called lazily from test, which lease instance
+	// when pool has no free instances and count of instances less then Size.
+	// Cleanup of the result called when package scope finished.
+	// Fixtures, which used by Create, should have ScopePackage, because the instance outlive the test.
+	Create func(e Env) (*GenericResult[T], error)
+
+	// Reset called when test return lease to the pool, it may be nil.
+	// Instance will be destroyed if Reset return error.
+	Reset func(instance T) error
+}
+
+// Pool is ScopePackage pool of expensive resources, which must not be shared by concurrently running tests.
+// Every test lease own instance, the instance returned to the pool at test cleanup.
+// Lease blocks until an instance will be available if all instances leased by other tests.
+//
+//	var dbPool = fixenv.NewPool(fixenv.PoolOptions[*DB]{Size: 4, Create: createDB, Reset: truncateTables})
+//
+//	func DB(e fixenv.Env) *DB {
+//		return dbPool.Lease(e)
+//	}
+type Pool[T any] struct {
+	id   int64
+	opts PoolOptions[T]
+}
+
+var lastPoolID int64
+
+// poolCacheKey is cache key of pool fixtures, because all pools call CacheResult from same code line
+type poolCacheKey struct {
+	Pool int64 `json:"pool"`
+}
+
+// NewPool create pool. It panics if options are invalid.
+func NewPool[T any](opts PoolOptions[T]) *Pool[T] {
+	switch {
+	case opts.Size <= 0:
+		panic(fmt.Errorf("fixenv: pool size must be greater then 0, got: %v", opts.Size))
+	case opts.Create == nil:
+		panic(errors.New("fixenv: pool create function must be set"))
+	}
+	return &Pool[T]{
+		id:   atomic.AddInt64(&lastPoolID, 1),
+		opts: opts,
+	}
+}
+
+// Lease return instance from the pool for the test of env.
+// The instance is cached with ScopeTest: all calls from same test return same instance.
+// The pool state has ScopePackage, then package must use fixenv.RunTests.
+func (p *Pool[T]) Lease(e Env) T {
+	if ht, ok := e.T().(helperT); ok {
+		ht.Helper()
+	}
+
+	key := poolCacheKey{Pool: p.id}
+	state := CacheResult(e, func() (*GenericResult[*poolState[T]], error) {
+		state := newPoolState(p.opts)
+		return NewGenericResultWithCleanup(state, state.close), nil
+	}, CacheOptions{Scope: ScopePackage, CacheKey: key})
+
+	return CacheResult(e, func() (*GenericResult[T], error) {
+		item, err := state.acquire(e)
+		if err != nil {
+			return nil, err
+		}
+		res := NewGenericResultWithCleanup(item.value, func() {
+			state.release(e, item)
+		})
+		// lease must return slot to the pool in keep on failure mode too, else next Lease wait forever
+		res.AlwaysCleanup = true
+		return res, nil
+	}, CacheOptions{Scope: ScopeTest, CacheKey: key})
+}
+
+type poolItem[T any] struct {
+	value   T
+	cleanup FixtureCleanupFunc
+}
+
+type poolState[T any] struct {
+	opts PoolOptions[T]
+
+	m       sync.Mutex
+	cond    *sync.Cond
+	free    []*poolItem[T]
+	all     map[*poolItem[T]]struct{}
+	creates int // count of instances in progress of create
+}
+
+func newPoolState[T any](opts PoolOptions[T]) *poolState[T] {
+	s := &poolState[T]{
+		opts: opts,
+		all:  make(map[*poolItem[T]]struct{}),
+	}
+	s.cond = sync.NewCond(&s.m)
+	return s
+}
+
+func (s *poolState[T]) acquire(e Env) (*poolItem[T], error) {
+	s.m.Lock()
+	for len(s.free) == 0 && len(s.all)+s.creates >= s.opts.Size {
+		s.cond.Wait()
+	}
+	if len(s.free) > 0 {
+		item := s.free[len(s.free)-1]
+		s.free = s.free[:len(s.free)-1]
+		s.m.Unlock()
+		return item, nil
+	}
+	s.creates++
+	s.m.Unlock()
+
+	var item *poolItem[T]
+
+	// deferred for free slot if Create stop goroutine by Fatalf, Goexit or panic
+	defer func() {
+		s.m.Lock()
+		defer s.m.Unlock()
+
+		s.creates--
+		if item == nil {
+			// free slot for other tests
+			s.cond.Signal()
+			return
+		}
+		s.all[item] = struct{}{}
+	}()
+
+	res, err := s.opts.Create(e)
+	if err != nil {
+		return nil, err
+	}
+	if res == nil {
+		return nil, errors.New("fixenv: pool create function returned nil result")
+	}
+	item = &poolItem[T]{value: res.Value, cleanup: res.Cleanup}
+	return item, nil
+}
+
+func (s *poolState[T]) release(e Env, item *poolItem[T]) {
+	if keepOnFailureEnabled() && isFailed(e.T()) {
+		e.T().Logf("fixenv: test failed, keep pool instance without reset and cleanup: %v", item.value)
+		s.forget(item)
+		return
+	}
+
+	if s.opts.Reset != nil {
+		if err := s.opts.Reset(item.value); err != nil {
+			e.T().Logf("fixenv: failed to reset pool instance, destroy it: %v", err)
+			s.destroy(item)
+			return
+		}
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.free = append(s.free, item)
+	s.cond.Signal()
+}
+
+// forget remove instance from the pool without cleanup, new instance will be created on demand
+func (s *poolState[T]) forget(item *poolItem[T]) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	delete(s.all, item)
+	s.cond.Signal()
+}
+
+func (s *poolState[T]) destroy(item *poolItem[T]) {
+	s.m.Lock()
+	delete(s.all, item)
+	s.cond.Signal()
+	s.m.Unlock()
+
+	if item.cleanup != nil {
+		item.cleanup()
+	}
+}
+
+func (s *poolState[T]) close() {
+	s.m.Lock()
+	items := make([]*poolItem[T], 0, len(s.all))
+	for item := range s.all {
+		items = append(items, item)
+	}
+	s.all = make(map[*poolItem[T]]struct{})
+	s.free = nil
+	s.m.Unlock()
+
+	for _, item := range items {
+		if item.cleanup != nil {
+			item.cleanup()
+		}
+	}
+}
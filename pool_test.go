@@ -0,0 +1,215 @@
+//go:build go1.18
+// +build go1.18
+
+package fixenv
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rekby/fixenv/internal"
+)
+
+// newPoolTestEnvs create package scope env and return constructor of test envs with same cache
+func newPoolTestEnvs(t *testing.T) (packageMock *internal.TestMock, newTest func(name string) (*EnvT, *internal.TestMock)) {
+	m := &sync.Mutex{}
+	scopes := make(map[string]*scopeInfo)
+
+	packageMock = &internal.TestMock{TestName: packageScopeName}
+	newEnv(packageMock, m, scopes).onCreate()
+
+	return packageMock, func(name string) (*EnvT, *internal.TestMock) {
+		tMock := &internal.TestMock{TestName: name}
+		e := newEnv(tMock, m, scopes)
+		e.onCreate()
+		return e, tMock
+	}
+}
+
+func TestPool(t *testing.T) {
+	t.Run("lease", func(t *testing.T) {
+		packageMock, newTest := newPoolTestEnvs(t)
+
+		var m sync.Mutex
+		created, resets, cleanups := 0, 0, 0
+		pool := NewPool(PoolOptions[int]{
+			Size: 2,
+			Create: func(e Env) (*GenericResult[int], error) {
+				m.Lock()
+				defer m.Unlock()
+				created++
+				return NewGenericResultWithCleanup(created, func() {
+					cleanups++
+				}), nil
+			},
+			Reset: func(instance int) error {
+				resets++
+				return nil
+			},
+		})
+
+		e1, t1 := newTest("t1")
+		e2, t2 := newTest("t2")
+		v1 := pool.Lease(e1)
+		requireEquals(t, v1, pool.Lease(e1))
+		v2 := pool.Lease(e2)
+		requireNotEquals(t, v1, v2)
+		requireEquals(t, 2, created)
+
+		e3, t3 := newTest("t3")
+		leased := make(chan int)
+		go func() {
+			leased <- pool.Lease(e3)
+		}()
+
+		select {
+		case <-leased:
+			t.Fatal("pool must block while all instances leased")
+		case <-time.After(10 * time.Millisecond):
+		}
+
+		t1.CallCleanup()
+		requireEquals(t, 1, resets)
+		requireEquals(t, v1, <-leased)
+		requireEquals(t, 2, created)
+
+		t2.CallCleanup()
+		t3.CallCleanup()
+		requireEquals(t, 3, resets)
+		requireEquals(t, 0, cleanups)
+
+		packageMock.CallCleanup()
+		requireEquals(t, 2, cleanups)
+	})
+
+	t.Run("reset_error", func(t *testing.T) {
+		packageMock, newTest := newPoolTestEnvs(t)
+
+		created, cleanups := 0, 0
+		pool := NewPool(PoolOptions[int]{
+			Size: 1,
+			Create: func(e Env) (*GenericResult[int], error) {
+				created++
+				return NewGenericResultWithCleanup(created, func() {
+					cleanups++
+				}), nil
+			},
+			Reset: func(instance int) error {
+				return errors.New("test")
+			},
+		})
+
+		e1, t1 := newTest("t1")
+		requireEquals(t, 1, pool.Lease(e1))
+		t1.CallCleanup()
+		requireEquals(t, 1, cleanups)
+
+		e2, t2 := newTest("t2")
+		requireEquals(t, 2, pool.Lease(e2))
+		t2.CallCleanup()
+		packageMock.CallCleanup()
+		requireEquals(t, 2, cleanups)
+	})
+
+	t.Run("create_error", func(t *testing.T) {
+		_, newTest := newPoolTestEnvs(t)
+
+		fail := true
+		pool := NewPool(PoolOptions[int]{
+			Size: 1,
+			Create: func(e Env) (*GenericResult[int], error) {
+				if fail {
+					return nil, errors.New("test")
+				}
+				return NewGenericResult(1), nil
+			},
+		})
+
+		e1, t1 := newTest("t1")
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			pool.Lease(e1)
+		}()
+		<-done
+		requireEquals(t, 1, len(t1.Fatals))
+
+		// slot of failed instance is free
+		fail = false
+		e2, _ := newTest("t2")
+		requireEquals(t, 1, pool.Lease(e2))
+	})
+
+	t.Run("create_fatal", func(t *testing.T) {
+		_, newTest := newPoolTestEnvs(t)
+
+		fail := true
+		pool := NewPool(PoolOptions[int]{
+			Size: 1,
+			Create: func(e Env) (*GenericResult[int], error) {
+				if fail {
+					e.T().Fatalf("test")
+				}
+				return NewGenericResult(1), nil
+			},
+		})
+
+		e1, t1 := newTest("t1")
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			pool.Lease(e1)
+		}()
+		<-done
+		requireEquals(t, 1, len(t1.Fatals))
+
+		// slot of failed instance is free
+		fail = false
+		e2, _ := newTest("t2")
+		requireEquals(t, 1, pool.Lease(e2))
+	})
+
+	t.Run("keep_on_failure", func(t *testing.T) {
+		setKeepOnFailure(true)
+		defer setKeepOnFailure(false)
+
+		_, newTest := newPoolTestEnvs(t)
+
+		created, cleanups := 0, 0
+		pool := NewPool(PoolOptions[int]{
+			Size: 1,
+			Create: func(e Env) (*GenericResult[int], error) {
+				created++
+				return NewGenericResultWithCleanup(created, func() { cleanups++ }), nil
+			},
+		})
+
+		e1, t1 := newTest("t1")
+		requireEquals(t, 1, pool.Lease(e1))
+		t1.Fail()
+		t1.CallCleanup()
+		requireEquals(t, 0, cleanups)
+
+		// failed instance kept out of pool, slot is free for new instance
+		e2, t2 := newTest("t2")
+		requireEquals(t, 2, pool.Lease(e2))
+		t2.CallCleanup()
+
+		e3, t3 := newTest("t3")
+		requireEquals(t, 2, pool.Lease(e3))
+		t3.CallCleanup()
+	})
+
+	t.Run("bad_options", func(t *testing.T) {
+		requirePanic(t, func() {
+			NewPool(PoolOptions[int]{Size: 0, Create: func(e Env) (*GenericResult[int], error) {
+				return nil, nil
+			}})
+		})
+		requirePanic(t, func() {
+			NewPool(PoolOptions[int]{Size: 1})
+		})
+	})
+}
@@ -0,0 +1,103 @@
+//go:build go1.18
+// +build go1.18
+
+package fixenv
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// ProviderFunction create value of type T for Resolve.
+// It may resolve other types from same env for dependencies.
+type ProviderFunction[T any] func(e Env) (*GenericResult[T], error)
+
+// providerCacheKey is cache key of provider value, because all providers called from same code line
+type providerCacheKey struct {
+	Registry int64       `json:"registry"`
+	Provider int64       `json:"provider"`
+	Key      interface{} `json:"key"`
+}
+
+// Provide register provider of type T in registry.
+// Results of provider cached as usual fixture with options: scope, cache key, etc.
+// Provide panics if fixture for T registered in the registry already.
+func Provide[T any](r *Registry, provider ProviderFunction[T], options ...CacheOptions) {
+	var cacheOptions CacheOptions
+	switch len(options) {
+	case 0:
+		// pass
+	case 1:
+		cacheOptions = options[0]
+	default:
+		panic(fmt.Errorf("max len of cache result cacheOptions is 1, given: %v", len(options)))
+	}
+
+	cacheOptions.CacheKey = providerCacheKey{
+		Registry: r.id,
+		Provider: r.nextFixtureID(),
+		Key:      cacheOptions.CacheKey,
+	}
+
+	fixture := func(e Env) T {
+		return CacheResult(e, func() (*GenericResult[T], error) {
+			return provider(e)
+		}, cacheOptions)
+	}
+	r.add(registryKey{t: typeOf[T]()}, reflect.ValueOf(fixture))
+}
+
+// Bind register interface type I, resolved by Impl value.
+// Bind replace previous fixture of I, then tests can swap implementations.
+// Bind panics if Impl doesn't implement I.
+func Bind[I any, Impl any](r *Registry) {
+	iType := typeOf[I]()
+	implType := typeOf[Impl]()
+	if iType.Kind() != reflect.Interface || !implType.Implements(iType) {
+		panic(fmt.Errorf("fixenv: %v must be interface, implemented by %v", iType, implType))
+	}
+
+	fixture := func(e Env) I {
+		return valueAs[I](reflect.ValueOf(ResolveFrom[Impl](e, r)))
+	}
+	r.set(registryKey{t: iType}, reflect.ValueOf(fixture))
+}
+
+// Resolve return value of type T from DefaultRegistry, see ResolveFrom
+func Resolve[T any](env Env) T {
+	if ht, ok := env.T().(helperT); ok {
+		ht.Helper()
+	}
+
+	return ResolveFrom[T](env, DefaultRegistry)
+}
+
+// ResolveFrom return value of type T from fixture, registered in registry by Provide, Bind or Register
+// without name. It fail test if type T not registered.
+func ResolveFrom[T any](env Env, r *Registry) T {
+	if ht, ok := env.T().(helperT); ok {
+		ht.Helper()
+	}
+
+	val, err := r.call(env, registryKey{t: typeOf[T]()}, injectTag{})
+	if err != nil {
+		env.T().Fatalf("fixenv: failed to resolve %v: %v", typeOf[T](), err)
+		var zero T
+		return zero
+	}
+	return valueAs[T](val)
+}
+
+// valueAs convert val to T. It doesn't use type assertion, because assertion of nil interface
+// panics, but provider of interface type may return nil value.
+func valueAs[T any](val reflect.Value) T {
+	var res T
+	if val.IsValid() {
+		reflect.ValueOf(&res).Elem().Set(val)
+	}
+	return res
+}
+
+func typeOf[T any]() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
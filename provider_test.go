@@ -0,0 +1,165 @@
+//go:build go1.18
+// +build go1.18
+
+package fixenv
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/rekby/fixenv/internal"
+)
+
+type providerTestStore interface {
+	Name() string
+}
+
+type providerTestDB struct {
+	dsn string
+}
+
+type providerTestRealStore struct {
+	db *providerTestDB
+}
+
+func (s *providerTestRealStore) Name() string {
+	return "real:" + s.db.dsn
+}
+
+type providerTestFakeStore struct{}
+
+func (s providerTestFakeStore) Name() string {
+	return "fake"
+}
+
+func TestProvideResolve(t *testing.T) {
+	r := NewRegistry()
+
+	dbCalls := 0
+	dbCleanups := 0
+	Provide(r, func(e Env) (*GenericResult[*providerTestDB], error) {
+		dbCalls++
+		return NewGenericResultWithCleanup(&providerTestDB{dsn: "db"}, func() {
+			dbCleanups++
+		}), nil
+	})
+	Provide(r, func(e Env) (*GenericResult[*providerTestRealStore], error) {
+		return NewGenericResult(&providerTestRealStore{db: ResolveFrom[*providerTestDB](e, r)}), nil
+	})
+	Provide(r, func(e Env) (*GenericResult[providerTestFakeStore], error) {
+		return NewGenericResult(providerTestFakeStore{}), nil
+	})
+
+	requirePanic(t, func() {
+		Provide(r, func(e Env) (*GenericResult[*providerTestDB], error) {
+			return NewGenericResult(&providerTestDB{}), nil
+		})
+	})
+
+	t.Run("dependencies", func(t *testing.T) {
+		tMock := &internal.TestMock{TestName: "mock"}
+		e := newTestEnv(tMock)
+
+		store := ResolveFrom[*providerTestRealStore](e, r)
+		db := ResolveFrom[*providerTestDB](e, r)
+		requireEquals(t, "real:db", store.Name())
+		requireTrue(t, store.db == db)
+		requireEquals(t, 1, dbCalls)
+
+		tMock.CallCleanup()
+		requireEquals(t, 1, dbCleanups)
+	})
+
+	t.Run("bind", func(t *testing.T) {
+		e := newTestEnv(&internal.TestMock{TestName: "mock"})
+
+		Bind[providerTestStore, *providerTestRealStore](r)
+		requireEquals(t, "real:db", ResolveFrom[providerTestStore](e, r).Name())
+
+		Bind[providerTestStore, providerTestFakeStore](r)
+		requireEquals(t, "fake", ResolveFrom[providerTestStore](e, r).Name())
+
+		requirePanic(t, func() {
+			Bind[*providerTestRealStore, *providerTestRealStore](r)
+		})
+		requirePanic(t, func() {
+			Bind[providerTestStore, *providerTestDB](r)
+		})
+	})
+
+	t.Run("inject", func(t *testing.T) {
+		e := newTestEnv(&internal.TestMock{TestName: "mock"})
+
+		var deps struct {
+			DB    *providerTestDB
+			Store *providerTestRealStore
+		}
+		r.Inject(e, &deps)
+		requireTrue(t, deps.Store.db == deps.DB)
+	})
+
+	t.Run("not_registered", func(t *testing.T) {
+		tMock := &internal.TestMock{TestName: "mock", SkipGoexit: true}
+		e := newTestEnv(tMock)
+
+		ResolveFrom[int](e, r)
+		requireEquals(t, 1, len(tMock.Fatals))
+		requireTrue(t, strings.Contains(tMock.Fatals[0].ResultString, "not registered"))
+	})
+}
+
+func TestProvideNilInterface(t *testing.T) {
+	r := NewRegistry()
+	Provide(r, func(e Env) (*GenericResult[io.Reader], error) {
+		return NewGenericResult[io.Reader](nil), nil
+	})
+	Provide(r, func(e Env) (*GenericResult[io.ReadCloser], error) {
+		return NewGenericResult[io.ReadCloser](nil), nil
+	})
+	Bind[io.Writer, io.ReadWriter](r)
+	Provide(r, func(e Env) (*GenericResult[io.ReadWriter], error) {
+		return NewGenericResult[io.ReadWriter](nil), nil
+	})
+
+	e := newTestEnv(&internal.TestMock{TestName: "mock"})
+	requireNil(t, ResolveFrom[io.Reader](e, r))
+	requireNil(t, ResolveFrom[io.Writer](e, r))
+
+	Bind[io.Reader, io.ReadCloser](r)
+	requireNil(t, ResolveFrom[io.Reader](e, r))
+}
+
+func TestProvideScope(t *testing.T) {
+	r := NewRegistry()
+
+	calls := 0
+	Provide(r, func(e Env) (*GenericResult[int], error) {
+		calls++
+		return NewGenericResult(calls), nil
+	}, CacheOptions{Scope: ScopeTestAndSubtests})
+
+	e := newTestEnv(&internal.TestMock{TestName: "mock"})
+	requireEquals(t, 1, ResolveFrom[int](e, r))
+	requireEquals(t, 1, ResolveFrom[int](e, r))
+
+	// same type in other registry is other fixture
+	r2 := NewRegistry()
+	Provide(r2, func(e Env) (*GenericResult[int], error) {
+		return NewGenericResult(10), nil
+	}, CacheOptions{Scope: ScopeTestAndSubtests})
+	requireEquals(t, 10, ResolveFrom[int](e, r2))
+}
+
+func TestResolveDefaultRegistry(t *testing.T) {
+	oldRegistry := DefaultRegistry
+	DefaultRegistry = NewRegistry()
+	defer func() {
+		DefaultRegistry = oldRegistry
+	}()
+
+	Provide(DefaultRegistry, func(e Env) (*GenericResult[string], error) {
+		return NewGenericResult("default"), nil
+	})
+	requireEquals(t, "default", Resolve[string](newTestEnv(t)))
+}
@@ -0,0 +1,154 @@
+package fixenv
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// ScopeCheckEnvName is name of environment variable, which set mode of scope violation check:
+// "fail" (default), "warn" or "off". It is same as CreateMainTestEnvOpts.ScopeCheck.
+const ScopeCheckEnvName = "FIXENV_SCOPE_CHECK"
+
+// ScopeCheckMode define reaction on scope violation: fixture with wide scope
+// depends on fixture with narrower scope while initialize.
+// For example ScopePackage fixture use ScopeTest temp dir, which will be removed after first test.
+type ScopeCheckMode int32
+
+const (
+	// ScopeCheckFail fail test on scope violation. Default value.
+	ScopeCheckFail ScopeCheckMode = iota
+
+	// ScopeCheckWarn log scope violation and continue test.
+	ScopeCheckWarn
+
+	// ScopeCheckOff disable the check.
+	ScopeCheckOff
+)
+
+// scopeCheckOpt is mode of scope check from CreateMainTestEnvOpts
+var scopeCheckOpt int32
+
+func setScopeCheck(mode ScopeCheckMode) {
+	atomic.StoreInt32(&scopeCheckOpt, int32(mode))
+}
+
+// scopeCheckMode return mode from options or environment variable if mode not set in options
+func scopeCheckMode() ScopeCheckMode {
+	if mode := ScopeCheckMode(atomic.LoadInt32(&scopeCheckOpt)); mode != ScopeCheckFail {
+		return mode
+	}
+
+	switch strings.ToLower(os.Getenv(ScopeCheckEnvName)) {
+	case "warn":
+		return ScopeCheckWarn
+	case "off":
+		return ScopeCheckOff
+	default:
+		return ScopeCheckFail
+	}
+}
+
+// initFrame is fixture in progress of initialization
+type initFrame struct {
+	key   cacheKey
+	scope CacheScope
+}
+
+// scopeWidth return order of scopes lifetime: wider scope has greater width
+func scopeWidth(scope CacheScope) int {
+	switch scope {
+	case ScopeTest:
+		return 0
+	case ScopeTestAndSubtests:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// pushInit register start of fixture initialization in current goroutine
+func (e *EnvT) pushInit(key cacheKey, scope CacheScope) {
+	id := goroutineID()
+
+	e.initM.Lock()
+	defer e.initM.Unlock()
+
+	if e.initStacks == nil {
+		e.initStacks = make(map[uint64][]initFrame)
+	}
+	e.initStacks[id] = append(e.initStacks[id], initFrame{key: key, scope: scope})
+	atomic.AddInt32(&e.inits, 1)
+}
+
+// popInit register end of fixture initialization in current goroutine
+func (e *EnvT) popInit() {
+	id := goroutineID()
+
+	e.initM.Lock()
+	defer e.initM.Unlock()
+
+	stack := e.initStacks[id]
+	if len(stack) <= 1 {
+		delete(e.initStacks, id)
+	} else {
+		e.initStacks[id] = stack[:len(stack)-1]
+	}
+	atomic.AddInt32(&e.inits, -1)
+}
+
+// checkScope check if fixture in progress of initialization in current goroutine
+// depends on fixture with narrower scope.
+func (e *EnvT) checkScope(key cacheKey, scope CacheScope) {
+	if atomic.LoadInt32(&e.inits) == 0 {
+		return
+	}
+
+	id := goroutineID()
+	e.initM.Lock()
+	stack := e.initStacks[id]
+	if len(stack) == 0 {
+		e.initM.Unlock()
+		return
+	}
+	outer := stack[len(stack)-1]
+	e.initM.Unlock()
+
+	if scopeWidth(scope) >= scopeWidth(outer.scope) || e.scopeName(scope) == e.scopeName(outer.scope) {
+		return
+	}
+
+	mode := scopeCheckMode()
+	if mode == ScopeCheckOff {
+		return
+	}
+
+	msg := fmt.Sprintf("fixenv: scope violation: fixture %v with scope %v depends on fixture %v with narrower scope %v",
+		outer.key, outer.scope, key, scope)
+	if ht, ok := e.t.(helperT); ok {
+		ht.Helper()
+	}
+	if mode == ScopeCheckWarn {
+		e.t.Logf("%v", msg)
+		return
+	}
+	e.t.Fatalf("%v", msg)
+}
+
+// goroutineID return id of current goroutine, parsed from header of its stack: "goroutine 123 [running]:".
+// Fixtures of one env may be initialized from many goroutines,
+// then fixtures in progress of initialization tracked per goroutine.
+func goroutineID() uint64 {
+	var buf [64]byte
+	stack := buf[:runtime.Stack(buf[:], false)]
+	stack = bytes.TrimPrefix(stack, []byte("goroutine "))
+	if i := bytes.IndexByte(stack, ' '); i >= 0 {
+		stack = stack[:i]
+	}
+	id, _ := strconv.ParseUint(string(stack), 10, 64)
+	return id
+}
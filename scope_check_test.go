@@ -0,0 +1,178 @@
+package fixenv
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/rekby/fixenv/internal"
+)
+
+func TestScopeCheck(t *testing.T) {
+	newEnvs := func() (e *EnvT, tMock *internal.TestMock) {
+		m := &sync.Mutex{}
+		scopes := make(map[string]*scopeInfo)
+
+		newEnv(&internal.TestMock{TestName: packageScopeName}, m, scopes).onCreate()
+		tMock = &internal.TestMock{TestName: "mock"}
+		e = newEnv(tMock, m, scopes)
+		e.onCreate()
+		return e, tMock
+	}
+
+	fixture := func(e Env, scope CacheScope, dep func()) int {
+		return e.CacheResult(func() (*Result, error) {
+			if dep != nil {
+				dep()
+			}
+			return NewResult(1), nil
+		}, CacheOptions{Scope: scope}).(int)
+	}
+
+	t.Run("fail", func(t *testing.T) {
+		e, tMock := newEnvs()
+		runUntilFatal(func() {
+			fixture(e, ScopePackage, func() {
+				fixture(e, ScopeTest, nil)
+			})
+		})
+		requireEquals(t, 1, len(tMock.Fatals))
+		msg := tMock.Fatals[0].ResultString
+		requireTrue(t, strings.Contains(msg, "scope violation"))
+		requireTrue(t, strings.Contains(msg, "with scope ScopePackage"))
+		requireTrue(t, strings.Contains(msg, "narrower scope ScopeTest"))
+		requireTrue(t, strings.Contains(msg, "TestScopeCheck"))
+	})
+
+	t.Run("fail_through_test_fixture", func(t *testing.T) {
+		e, tMock := newEnvs()
+		runUntilFatal(func() {
+			fixture(e, ScopeTest, func() {
+				fixture(e, ScopePackage, func() {
+					fixture(e, ScopeTest, nil)
+				})
+			})
+		})
+		requireEquals(t, 1, len(tMock.Fatals))
+		requireTrue(t, strings.Contains(tMock.Fatals[0].ResultString, "with scope ScopePackage"))
+		requireTrue(t, strings.Contains(tMock.Fatals[0].ResultString, "narrower scope ScopeTest"))
+	})
+
+	t.Run("warn", func(t *testing.T) {
+		setScopeCheck(ScopeCheckWarn)
+		defer setScopeCheck(ScopeCheckFail)
+
+		e, tMock := newEnvs()
+		fixture(e, ScopePackage, func() {
+			fixture(e, ScopeTestAndSubtests, nil)
+		})
+		requireEquals(t, 0, len(tMock.Fatals))
+		requireEquals(t, 1, len(tMock.Logs))
+		requireTrue(t, strings.Contains(tMock.Logs[0].ResultString, "scope violation"))
+	})
+
+	t.Run("off", func(t *testing.T) {
+		setenvForTest(t, ScopeCheckEnvName, "off")
+
+		e, tMock := newEnvs()
+		fixture(e, ScopePackage, func() {
+			fixture(e, ScopeTest, nil)
+		})
+		requireEquals(t, 0, len(tMock.Fatals))
+		requireEquals(t, 0, len(tMock.Logs))
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		e, tMock := newEnvs()
+		fixture(e, ScopeTest, func() {
+			fixture(e, ScopePackage, nil)
+		})
+
+		// same lifetime for top level test
+		fixture(e, ScopeTestAndSubtests, func() {
+			fixture(e, ScopeTest, nil)
+		})
+
+		// scope checked for fixtures in progress of initialization only
+		fixture(e, ScopePackage, nil)
+		fixture(e, ScopeTest, nil)
+
+		// fixtures of ScopeTest are not tracked
+		fixture(e, ScopeTest, func() {
+			requireEquals(t, int32(0), atomic.LoadInt32(&e.inits))
+		})
+
+		requireEquals(t, 0, len(tMock.Fatals))
+		requireEquals(t, 0, len(tMock.Logs))
+	})
+}
+
+func TestScopeCheck_Goroutines(t *testing.T) {
+	m := &sync.Mutex{}
+	scopes := make(map[string]*scopeInfo)
+	newEnv(&internal.TestMock{TestName: packageScopeName}, m, scopes).onCreate()
+	tMock := &internal.TestMock{TestName: "mock"}
+	e := newEnv(tMock, m, scopes)
+	e.onCreate()
+
+	started := make(chan bool)
+	release := make(chan bool)
+	done := make(chan bool)
+	go func() {
+		defer close(done)
+		e.CacheResult(func() (*Result, error) {
+			close(started)
+			<-release
+			return NewResult(1), nil
+		}, CacheOptions{Scope: ScopePackage})
+	}()
+
+	<-started
+	// other goroutine init test fixture while package fixture in progress
+	e.CacheResult(func() (*Result, error) {
+		return NewResult(2), nil
+	}, CacheOptions{Scope: ScopeTest})
+	close(release)
+	<-done
+
+	requireEquals(t, 0, len(tMock.Fatals))
+	requireEquals(t, 0, len(tMock.Logs))
+	requireEquals(t, 0, len(e.initStacks))
+	requireEquals(t, int32(0), e.inits)
+}
+
+func TestGoroutineID(t *testing.T) {
+	id := goroutineID()
+	requireTrue(t, id != 0)
+	requireEquals(t, id, goroutineID())
+
+	var otherID uint64
+	done := make(chan bool)
+	go func() {
+		otherID = goroutineID()
+		close(done)
+	}()
+	<-done
+	requireTrue(t, otherID != 0)
+	requireNotEquals(t, id, otherID)
+}
+
+func TestScopeCheckMode(t *testing.T) {
+	table := map[string]ScopeCheckMode{
+		"":     ScopeCheckFail,
+		"fail": ScopeCheckFail,
+		"warn": ScopeCheckWarn,
+		"WARN": ScopeCheckWarn,
+		"off":  ScopeCheckOff,
+	}
+	for val, mode := range table {
+		setenvForTest(t, ScopeCheckEnvName, val)
+		requireEquals(t, mode, scopeCheckMode())
+	}
+
+	setScopeCheck(ScopeCheckOff)
+	defer setScopeCheck(ScopeCheckFail)
+	setenvForTest(t, ScopeCheckEnvName, "warn")
+	requireEquals(t, ScopeCheckOff, scopeCheckMode())
+}
@@ -5,13 +5,40 @@ import "sync"
 type scopeInfo struct {
 	t T
 
+	// c is cache shard of the scope, it dropped with scope info when the scope finished
+	c *cache
+
+	// envs is count of alive envs of the scope, protected by env mutex
+	envs int
+
 	m         sync.Mutex
 	cacheKeys []cacheKey
+
+	// beforeCleanup called once before first fixture cleanup of the scope
+	beforeCleanup sync.Once
+
+	// resets of wider scope fixtures, used by the scope
+	resetKeys map[cacheKey]bool
+	resets    []FixtureResetFunc
+
+	// mutation checks of wider scope fixtures, used by the scope
+	mutationCheckKeys map[cacheKey]bool
+	mutationChecks    []mutationCheck
+}
+
+// mutationCheck is check of shared value of fixture at end of test
+type mutationCheck struct {
+	// check compare value with digest, saved before the test
+	check func()
+
+	// rebase save digest of value, called after resets of the test
+	rebase func()
 }
 
 func newScopeInfo(t T) *scopeInfo {
 	return &scopeInfo{
 		t: t,
+		c: newCache(),
 	}
 }
 
@@ -22,6 +49,19 @@ func (s *scopeInfo) AddKey(key cacheKey) {
 	s.cacheKeys = append(s.cacheKeys, key)
 }
 
+// RemoveKey remove key, evicted from cache before scope finished
+func (s *scopeInfo) RemoveKey(key cacheKey) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	for i, k := range s.cacheKeys {
+		if k == key {
+			s.cacheKeys = append(s.cacheKeys[:i], s.cacheKeys[i+1:]...)
+			return
+		}
+	}
+}
+
 func (s *scopeInfo) Keys() []cacheKey {
 	s.m.Lock()
 	defer s.m.Unlock()
@@ -30,3 +70,58 @@ func (s *scopeInfo) Keys() []cacheKey {
 	copy(res, s.cacheKeys)
 	return res
 }
+
+// AddReset register reset of wider scope fixture once per key
+func (s *scopeInfo) AddReset(key cacheKey, reset FixtureResetFunc) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if s.resetKeys[key] {
+		return
+	}
+	if s.resetKeys == nil {
+		s.resetKeys = make(map[cacheKey]bool)
+	}
+	s.resetKeys[key] = true
+	s.resets = append(s.resets, reset)
+}
+
+// Resets return registered resets in reverse order and forget them
+func (s *scopeInfo) Resets() []FixtureResetFunc {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	res := make([]FixtureResetFunc, 0, len(s.resets))
+	for i := len(s.resets) - 1; i >= 0; i-- {
+		res = append(res, s.resets[i])
+	}
+	s.resets = nil
+	s.resetKeys = nil
+	return res
+}
+
+// AddMutationCheck register mutation check of wider scope fixture once per key
+func (s *scopeInfo) AddMutationCheck(key cacheKey, check mutationCheck) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if s.mutationCheckKeys[key] {
+		return
+	}
+	if s.mutationCheckKeys == nil {
+		s.mutationCheckKeys = make(map[cacheKey]bool)
+	}
+	s.mutationCheckKeys[key] = true
+	s.mutationChecks = append(s.mutationChecks, check)
+}
+
+// MutationChecks return registered mutation checks and forget them
+func (s *scopeInfo) MutationChecks() []mutationCheck {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	res := s.mutationChecks
+	s.mutationChecks = nil
+	s.mutationCheckKeys = nil
+	return res
+}
@@ -14,9 +14,9 @@ func TestScopeInfo_AddKey(t *testing.T) {
 		requireEquals(t, t, si.t)
 		requireEquals(t, len(si.cacheKeys), 0)
 
-		si.AddKey("asd")
-		si.AddKey("ddd")
-		requireEquals(t, []cacheKey{"asd", "ddd"}, si.cacheKeys)
+		si.AddKey(testCacheKey("asd"))
+		si.AddKey(testCacheKey("ddd"))
+		requireEquals(t, []cacheKey{testCacheKey("asd"), testCacheKey("ddd")}, si.cacheKeys)
 	})
 
 	t.Run("race", func(t *testing.T) {
@@ -25,7 +25,7 @@ func TestScopeInfo_AddKey(t *testing.T) {
 		count := 10000
 		source := make([]cacheKey, count)
 		for i := 0; i < count; i++ {
-			source[i] = cacheKey(strconv.Itoa(i))
+			source[i] = testCacheKey(strconv.Itoa(i))
 		}
 
 		var wg sync.WaitGroup
@@ -39,8 +39,8 @@ func TestScopeInfo_AddKey(t *testing.T) {
 		wg.Wait()
 
 		sort.Slice(si.cacheKeys, func(i, j int) bool {
-			iInt, _ := strconv.Atoi(string(si.cacheKeys[i]))
-			jInt, _ := strconv.Atoi(string(si.cacheKeys[j]))
+			iInt, _ := strconv.Atoi(si.cacheKeys[i].params.(string))
+			jInt, _ := strconv.Atoi(si.cacheKeys[j].params.(string))
 
 			return iInt < jInt
 		})
@@ -52,17 +52,17 @@ func TestScopeInfo_AddKey(t *testing.T) {
 func TestScopeInfo_Keys(t *testing.T) {
 	t.Run("simple", func(t *testing.T) {
 		si := newScopeInfo(t)
-		si.AddKey("asd")
-		si.AddKey("kkk")
+		si.AddKey(testCacheKey("asd"))
+		si.AddKey(testCacheKey("kkk"))
 
 		keys := si.Keys()
-		requireEquals(t, []cacheKey{"asd", "kkk"}, keys)
+		requireEquals(t, []cacheKey{testCacheKey("asd"), testCacheKey("kkk")}, keys)
 	})
 
 	t.Run("mutex", func(t *testing.T) {
 		si := newScopeInfo(t)
-		si.AddKey("asd")
-		si.AddKey("kkk")
+		si.AddKey(testCacheKey("asd"))
+		si.AddKey(testCacheKey("kkk"))
 
 		si.m.Lock()
 		var keys []cacheKey
@@ -78,6 +78,49 @@ func TestScopeInfo_Keys(t *testing.T) {
 
 		si.m.Unlock()
 		wg.Wait()
-		requireEquals(t, []cacheKey{"asd", "kkk"}, keys)
+		requireEquals(t, []cacheKey{testCacheKey("asd"), testCacheKey("kkk")}, keys)
 	})
 }
+
+func TestScopeInfo_AddReset(t *testing.T) {
+	si := newScopeInfo(t)
+
+	var calls []int
+	si.AddReset(testCacheKey("a"), func() { calls = append(calls, 1) })
+	si.AddReset(testCacheKey("a"), func() { calls = append(calls, 2) })
+	si.AddReset(testCacheKey("b"), func() { calls = append(calls, 3) })
+
+	for _, reset := range si.Resets() {
+		reset()
+	}
+	requireEquals(t, []int{3, 1}, calls)
+	requireEquals(t, 0, len(si.Resets()))
+}
+
+func TestScopeInfo_AddMutationCheck(t *testing.T) {
+	si := newScopeInfo(t)
+
+	var calls []int
+	si.AddReset(testCacheKey("a"), func() { calls = append(calls, 0) })
+	si.AddMutationCheck(testCacheKey("a"), mutationCheck{check: func() { calls = append(calls, 1) }})
+	si.AddMutationCheck(testCacheKey("a"), mutationCheck{check: func() { calls = append(calls, 2) }})
+	si.AddMutationCheck(testCacheKey("b"), mutationCheck{check: func() { calls = append(calls, 3) }})
+
+	for _, check := range si.MutationChecks() {
+		check.check()
+	}
+	requireEquals(t, []int{1, 3}, calls)
+	requireEquals(t, 0, len(si.MutationChecks()))
+	requireEquals(t, 1, len(si.Resets()))
+}
+
+func TestScopeInfo_RemoveKey(t *testing.T) {
+	si := newScopeInfo(t)
+	si.AddKey(testCacheKey("a"))
+	si.AddKey(testCacheKey("b"))
+	si.AddKey(testCacheKey("c"))
+
+	si.RemoveKey(testCacheKey("b"))
+	si.RemoveKey(testCacheKey("unknown"))
+	requireEquals(t, []cacheKey{testCacheKey("a"), testCacheKey("c")}, si.Keys())
+}
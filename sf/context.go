@@ -5,6 +5,7 @@ import (
 	"github.com/rekby/fixenv"
 )
 
+// Context return context, which will be canceled after test finished
 func Context(e fixenv.Env) context.Context {
 	f := func() (*fixenv.Result, error) {
 		ctx, ctxCancel := context.WithCancel(context.Background())
@@ -12,3 +13,12 @@ func Context(e fixenv.Env) context.Context {
 	}
 	return e.CacheResult(f).(context.Context)
 }
+
+// ContextWithEnv return context same as Context, which carry the env.
+// Code under test, which receive the context, can get env by fixenv.FromContext.
+func ContextWithEnv(e fixenv.Env) context.Context {
+	f := func() (*fixenv.Result, error) {
+		return fixenv.NewResult(fixenv.WithEnv(Context(e), e)), nil
+	}
+	return e.CacheResult(f).(context.Context)
+}
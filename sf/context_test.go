@@ -21,3 +21,22 @@ func TestContext(t *testing.T) {
 		t.Fatal(ctx.Err())
 	}
 }
+
+func TestContextWithEnv(t *testing.T) {
+	tm := &internal.TestMock{}
+
+	e := fixenv.New(tm)
+	ctx := ContextWithEnv(e)
+	ctxEnv, ok := fixenv.FromContext(ctx)
+	if !ok || ctxEnv != e {
+		t.Fatal(ctxEnv, ok)
+	}
+	if ctx != ContextWithEnv(e) {
+		t.Fatal("context must be cached")
+	}
+
+	tm.CallCleanup()
+	if ctx.Err() != context.Canceled {
+		t.Fatal(ctx.Err())
+	}
+}
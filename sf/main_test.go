@@ -0,0 +1,12 @@
+package sf
+
+import (
+	"os"
+	"testing"
+
+	"github.com/rekby/fixenv"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(fixenv.RunTests(m))
+}
@@ -0,0 +1,152 @@
+package sf
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/rekby/fixenv"
+)
+
+// RequireFailEnvName is name of environment variable, which switch Require fixtures
+// from skip test to fail test when requirement not satisfied.
+// It useful for CI, where all requirements must be satisfied: FIXENV_REQUIRE_FAIL=1
+const RequireFailEnvName = "FIXENV_REQUIRE_FAIL"
+
+// requireKey is cache key for requirement checks
+type requireKey struct {
+	Kind string   `json:"kind"`
+	Args []string `json:"args"`
+}
+
+// Require check custom requirement once per package and skip test if check return error.
+// The error is used as skip reason.
+// Results of checks cached with fixenv.ScopePackage by name, then package must be run with fixenv.RunTests.
+// All requirements may be combined by call some Require functions one by one.
+func Require(e fixenv.Env, name string, check func() error) {
+	requireCached(e, requireKey{Kind: "custom", Args: []string{name}}, func() (interface{}, error) {
+		return nil, check()
+	})
+}
+
+// RequireEnvVar return value of environment variable and skip test if the variable is empty
+func RequireEnvVar(e fixenv.Env, name string) string {
+	res := requireCached(e, requireKey{Kind: "env", Args: []string{name}}, func() (interface{}, error) {
+		val := os.Getenv(name)
+		if val == "" {
+			return nil, fmt.Errorf("environment variable %q is not set", name)
+		}
+		return val, nil
+	})
+	return res.(string)
+}
+
+// RequireBinary return full path to binary and skip test if the binary not found in PATH
+func RequireBinary(e fixenv.Env, name string) string {
+	res := requireCached(e, requireKey{Kind: "binary", Args: []string{name}}, func() (interface{}, error) {
+		path, err := exec.LookPath(name)
+		if err != nil {
+			return nil, fmt.Errorf("binary %q not found: %v", name, err)
+		}
+		return path, nil
+	})
+	return res.(string)
+}
+
+// RequireOS skip test if current GOOS not in goos list
+func RequireOS(e fixenv.Env, goos ...string) {
+	requireCached(e, requireKey{Kind: "os", Args: goos}, func() (interface{}, error) {
+		for _, name := range goos {
+			if name == runtime.GOOS {
+				return nil, nil
+			}
+		}
+		return nil, fmt.Errorf("test require os %v, current os: %q", goos, runtime.GOOS)
+	})
+}
+
+// RequireRoot skip test if current process run without root privileges
+func RequireRoot(e fixenv.Env) {
+	requireCached(e, requireKey{Kind: "root"}, func() (interface{}, error) {
+		if uid := os.Geteuid(); uid != 0 {
+			return nil, fmt.Errorf("test require root privileges, current euid: %v", uid)
+		}
+		return nil, nil
+	})
+}
+
+// RequireFreeDisk skip test if free space on disk, contained path, less then minBytes
+func RequireFreeDisk(e fixenv.Env, path string, minBytes uint64) {
+	key := requireKey{Kind: "free_disk", Args: []string{path, strconv.FormatUint(minBytes, 10)}}
+	requireCached(e, key, func() (interface{}, error) {
+		free, err := freeDiskSpace(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get free disk space for %q: %v", path, err)
+		}
+		if free < minBytes {
+			return nil, fmt.Errorf("test require %v free bytes at %q, available: %v", minBytes, path, free)
+		}
+		return nil, nil
+	})
+}
+
+// RequireShortMode skip test if short mode (-test.short flag) is not equal to short.
+// Use RequireShortMode(e, false) for skip long tests in short mode.
+// Outside of test binary or before flags parsed short mode is false.
+func RequireShortMode(e fixenv.Env, short bool) {
+	key := requireKey{Kind: "short", Args: []string{strconv.FormatBool(short)}}
+	requireCached(e, key, func() (interface{}, error) {
+		if shortMode(flag.CommandLine) != short {
+			return nil, fmt.Errorf("test require short mode: %v", short)
+		}
+		return nil, nil
+	})
+}
+
+// shortMode return value of -test.short flag from flags.
+// It doesn't use testing.Short, because it panics if flags of testing package not registered or not parsed,
+// for example in standalone env.
+func shortMode(flags *flag.FlagSet) bool {
+	if !flags.Parsed() {
+		return false
+	}
+	f := flags.Lookup("test.short")
+	if f == nil {
+		return false
+	}
+	getter, ok := f.Value.(flag.Getter)
+	if !ok {
+		return false
+	}
+	short, _ := getter.Get().(bool)
+	return short
+}
+
+func requireCached(e fixenv.Env, key requireKey, check func() (interface{}, error)) interface{} {
+	f := func() (*fixenv.Result, error) {
+		res, err := check()
+		if err != nil {
+			return nil, requireError(key, err)
+		}
+		return fixenv.NewResult(res), nil
+	}
+	return e.CacheResult(f, fixenv.CacheOptions{Scope: fixenv.ScopePackage, CacheKey: key})
+}
+
+// requireError convert failed check to skip error, or to failure if it required by env
+func requireError(key requireKey, err error) error {
+	reason := fmt.Errorf("requirement %v(%v) is not satisfied: %v", key.Kind, strings.Join(key.Args, ", "), err)
+	if requireFailEnabled() {
+		return reason
+	}
+	return fmt.Errorf("%w: %v", fixenv.ErrSkipTest, reason)
+}
+
+func requireFailEnabled() bool {
+	val, err := strconv.ParseBool(os.Getenv(RequireFailEnvName))
+	return err == nil && val
+}
@@ -0,0 +1,15 @@
+//go:build !linux && !darwin && !freebsd
+// +build !linux,!darwin,!freebsd
+
+package sf
+
+import (
+	"errors"
+	"runtime"
+)
+
+var errFreeDiskUnsupported = errors.New("free disk space check is not supported on " + runtime.GOOS)
+
+func freeDiskSpace(_ string) (uint64, error) {
+	return 0, errFreeDiskUnsupported
+}
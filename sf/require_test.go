@@ -0,0 +1,232 @@
+package sf
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/rekby/fixenv"
+	"github.com/rekby/fixenv/internal"
+)
+
+func TestRequire(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		tm := &internal.TestMock{TestName: t.Name()}
+		t.Cleanup(tm.CallCleanup)
+		e := fixenv.New(tm)
+
+		name := uniqueRequireName()
+		calls := 0
+		check := func() error {
+			calls++
+			return nil
+		}
+		Require(e, name, check)
+		Require(e, name, check)
+		if calls != 1 {
+			t.Fatalf("check must be called once, called: %v", calls)
+		}
+		if tm.SkipCount != 0 {
+			t.Fatal(tm.SkipCount)
+		}
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		tm := &internal.TestMock{TestName: t.Name()}
+		t.Cleanup(tm.CallCleanup)
+		e := fixenv.New(tm)
+
+		name := uniqueRequireName()
+		calls := 0
+		check := func() error {
+			calls++
+			return errors.New("test reason")
+		}
+		requireRunUntilExit(func() { Require(e, name, check) })
+		requireRunUntilExit(func() { Require(e, name, check) })
+		if calls != 1 {
+			t.Fatalf("check must be called once, called: %v", calls)
+		}
+		if tm.SkipCount != 2 {
+			t.Fatal(tm.SkipCount)
+		}
+		if len(tm.Logs) == 0 || !strings.Contains(tm.Logs[0].ResultString, "test reason") {
+			t.Fatalf("skip reason must be logged: %#v", tm.Logs)
+		}
+	})
+
+	t.Run("fail_by_env", func(t *testing.T) {
+		setenvForTest(t, RequireFailEnvName, "1")
+		tm := &internal.TestMock{TestName: t.Name()}
+		t.Cleanup(tm.CallCleanup)
+		e := fixenv.New(tm)
+
+		requireRunUntilExit(func() {
+			Require(e, uniqueRequireName(), func() error {
+				return errors.New("test reason")
+			})
+		})
+		if tm.SkipCount != 0 {
+			t.Fatal(tm.SkipCount)
+		}
+		if len(tm.Fatals) != 1 || !strings.Contains(tm.Fatals[0].ResultString, "test reason") {
+			t.Fatalf("fatals: %#v", tm.Fatals)
+		}
+	})
+}
+
+func TestRequireEnvVar(t *testing.T) {
+	setenvForTest(t, "FIXENV_SF_TEST_REQUIRE_ENV", "val")
+	e := fixenv.New(t)
+	if val := RequireEnvVar(e, "FIXENV_SF_TEST_REQUIRE_ENV"); val != "val" {
+		t.Fatal(val)
+	}
+
+	t.Run("empty", func(t *testing.T) {
+		tm := &internal.TestMock{TestName: t.Name()}
+		t.Cleanup(tm.CallCleanup)
+		e := fixenv.New(tm)
+		requireRunUntilExit(func() { RequireEnvVar(e, "FIXENV_SF_TEST_REQUIRE_ENV_EMPTY") })
+		if tm.SkipCount != 1 {
+			t.Fatal(tm.SkipCount)
+		}
+	})
+}
+
+func TestRequireBinary(t *testing.T) {
+	tm := &internal.TestMock{TestName: t.Name()}
+	t.Cleanup(tm.CallCleanup)
+	e := fixenv.New(tm)
+	requireRunUntilExit(func() { RequireBinary(e, "fixenv-not-existed-binary") })
+	if tm.SkipCount != 1 {
+		t.Fatal(tm.SkipCount)
+	}
+}
+
+func TestRequireOS(t *testing.T) {
+	e := fixenv.New(t)
+	RequireOS(e, "not-existed-os", runtime.GOOS)
+
+	tm := &internal.TestMock{TestName: t.Name() + "-mock"}
+	t.Cleanup(tm.CallCleanup)
+	mockEnv := fixenv.New(tm)
+	requireRunUntilExit(func() { RequireOS(mockEnv, "not-existed-os") })
+	if tm.SkipCount != 1 {
+		t.Fatal(tm.SkipCount)
+	}
+}
+
+func TestRequireRoot(t *testing.T) {
+	tm := &internal.TestMock{TestName: t.Name()}
+	t.Cleanup(tm.CallCleanup)
+	e := fixenv.New(tm)
+	requireRunUntilExit(func() { RequireRoot(e) })
+
+	expectedSkip := 1
+	if os.Geteuid() == 0 {
+		expectedSkip = 0
+	}
+	if tm.SkipCount != expectedSkip {
+		t.Fatal(tm.SkipCount)
+	}
+}
+
+func TestRequireFreeDisk(t *testing.T) {
+	tm := &internal.TestMock{TestName: t.Name()}
+	t.Cleanup(tm.CallCleanup)
+	e := fixenv.New(tm)
+	requireRunUntilExit(func() { RequireFreeDisk(e, os.TempDir(), 1<<62) })
+	if tm.SkipCount != 1 {
+		t.Fatal(tm.SkipCount)
+	}
+}
+
+func TestRequireShortMode(t *testing.T) {
+	e := fixenv.New(t)
+	RequireShortMode(e, testing.Short())
+
+	t.Run("skip", func(t *testing.T) {
+		tm := &internal.TestMock{TestName: t.Name()}
+		t.Cleanup(tm.CallCleanup)
+		e := fixenv.New(tm)
+		requireRunUntilExit(func() { RequireShortMode(e, !testing.Short()) })
+		if tm.SkipCount != 1 {
+			t.Fatal(tm.SkipCount)
+		}
+	})
+}
+
+func TestShortMode(t *testing.T) {
+	if shortMode(flag.CommandLine) != testing.Short() {
+		t.Fatal()
+	}
+
+	t.Run("not_parsed", func(t *testing.T) {
+		flags := flag.NewFlagSet("test", flag.ContinueOnError)
+		flags.Bool("test.short", true, "")
+		if shortMode(flags) {
+			t.Fatal()
+		}
+	})
+
+	t.Run("not_registered", func(t *testing.T) {
+		flags := flag.NewFlagSet("test", flag.ContinueOnError)
+		if err := flags.Parse(nil); err != nil {
+			t.Fatal(err)
+		}
+		if shortMode(flags) {
+			t.Fatal()
+		}
+	})
+
+	t.Run("short", func(t *testing.T) {
+		flags := flag.NewFlagSet("test", flag.ContinueOnError)
+		flags.Bool("test.short", false, "")
+		if err := flags.Parse([]string{"-test.short"}); err != nil {
+			t.Fatal(err)
+		}
+		if !shortMode(flags) {
+			t.Fatal()
+		}
+	})
+}
+
+var requireNameCounter int64
+
+// uniqueRequireName return new requirement name for every call
+// because package scope cache shared between tests and runs with -count
+func uniqueRequireName() string {
+	return "require-" + strconv.FormatInt(atomic.AddInt64(&requireNameCounter, 1), 10)
+}
+
+// requireRunUntilExit run f in separate goroutine and wait until it finished or goexit
+func requireRunUntilExit(f func()) {
+	done := make(chan bool)
+	go func() {
+		defer close(done)
+		f()
+	}()
+	<-done
+}
+
+// setenvForTest set environment variable and restore previous value on test cleanup.
+// It used instead of t.Setenv, which not exists in go 1.16.
+func setenvForTest(t *testing.T, name, value string) {
+	t.Helper()
+	prevValue, prevExist := os.LookupEnv(name)
+	if err := os.Setenv(name, value); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if prevExist {
+			_ = os.Setenv(name, prevValue)
+		} else {
+			_ = os.Unsetenv(name)
+		}
+	})
+}
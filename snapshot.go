@@ -0,0 +1,160 @@
+package fixenv
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// FixtureStatus is kind of cached fixture result
+type FixtureStatus int
+
+const (
+	// FixtureValue - fixture returned value
+	FixtureValue FixtureStatus = iota
+
+	// FixtureError - fixture returned error, every call of the fixture fail test
+	FixtureError
+
+	// FixtureSkip - fixture returned ErrSkipTest, every call of the fixture skip test
+	FixtureSkip
+)
+
+// String return name of status
+func (s FixtureStatus) String() string {
+	switch s {
+	case FixtureValue:
+		return "value"
+	case FixtureError:
+		return "error"
+	case FixtureSkip:
+		return "skip"
+	default:
+		return "unknown"
+	}
+}
+
+// FixtureInfo is description of cached fixture result, see EnvT.Fixtures and Snapshot
+type FixtureInfo struct {
+	// Scope of the fixture and ScopeName - name of test or TestMain for package scope
+	Scope     CacheScope
+	ScopeName string
+
+	// Function and File of fixture
+	Function string
+	File     string
+
+	// Key is cache key of the fixture call: raw value of plain key (strings, bools, integers,
+	// plain structs and arrays of them), json string of serialized key, key from CacheKeyer
+	// or raw key of CacheResultKey. Keys of CacheResultKey2 are []interface{}{key1, key2}.
+	Key interface{}
+
+	// Created is time of fixture result creation
+	Created time.Time
+
+	// Hits is count of fixture calls, which returned cached result
+	Hits int64
+
+	Status FixtureStatus
+
+	// Err is error of fixture for FixtureError and FixtureSkip status
+	Err error
+}
+
+// Fixtures return cached fixture results, visible from the env:
+// results of scopes of the test, its parent test and package scope.
+// Results sorted by creation time.
+func (e *EnvT) Fixtures() []FixtureInfo {
+	var scopeNames []string
+	for _, scope := range []CacheScope{ScopeTest, ScopeTestAndSubtests, ScopePackage} {
+		name := e.scopeName(scope)
+		if len(scopeNames) == 0 || scopeNames[len(scopeNames)-1] != name {
+			scopeNames = append(scopeNames, name)
+		}
+	}
+
+	e.m.Lock()
+	scopes := make([]*scopeInfo, 0, len(scopeNames))
+	for _, name := range scopeNames {
+		if si := e.scopes[name]; si != nil {
+			scopes = append(scopes, si)
+		}
+	}
+	e.m.Unlock()
+
+	return fixtureInfos(scopes)
+}
+
+// Snapshot return all cached fixture results of all alive scopes.
+// It is useful for debug dumps on test failure.
+// Results sorted by creation time.
+func Snapshot() []FixtureInfo {
+	return snapshot(&globalMutex, globalScopeInfo)
+}
+
+func snapshot(m sync.Locker, scopes map[string]*scopeInfo) []FixtureInfo {
+	m.Lock()
+	list := make([]*scopeInfo, 0, len(scopes))
+	for _, si := range scopes {
+		list = append(list, si)
+	}
+	m.Unlock()
+
+	return fixtureInfos(list)
+}
+
+func fixtureInfos(scopes []*scopeInfo) []FixtureInfo {
+	var res []FixtureInfo
+	for _, si := range scopes {
+		for _, item := range si.c.items() {
+			res = append(res, newFixtureInfo(item))
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Created.Before(res[j].Created)
+	})
+	return res
+}
+
+func newFixtureInfo(item cacheItem) FixtureInfo {
+	info := FixtureInfo{
+		Scope:     item.key.scope,
+		ScopeName: item.key.scopeName,
+		Key:       decodeKeyParams(item.key.params),
+		Err:       item.val.err,
+	}
+	if item.key.fixture != nil {
+		info.Function = item.key.fixture.function
+		info.File = item.key.fixture.file
+	}
+	if item.val.stat != nil {
+		info.Created = item.val.stat.created
+		info.Hits = atomic.LoadInt64(&item.val.stat.hits)
+	}
+
+	switch {
+	case item.val.err == nil:
+		info.Status = FixtureValue
+	case errors.Is(item.val.err, ErrSkipTest):
+		info.Status = FixtureSkip
+	default:
+		info.Status = FixtureError
+	}
+	return info
+}
+
+// decodeKeyParams return cache key of fixture call from params of cacheKey
+func decodeKeyParams(params interface{}) interface{} {
+	switch p := params.(type) {
+	case jsonKeyParams:
+		return p.data
+	case keyerKeyParams:
+		return decodeKeyParams(p.key)
+	case typedKeyParams:
+		return decodeKeyParams(p.typedKeyValue())
+	default:
+		return p
+	}
+}
@@ -0,0 +1,127 @@
+package fixenv
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/rekby/fixenv/internal"
+)
+
+func TestEnv_Fixtures(t *testing.T) {
+	m := &sync.Mutex{}
+	scopes := make(map[string]*scopeInfo)
+	packageEnv := newEnv(&internal.TestMock{TestName: packageScopeName}, m, scopes)
+	packageEnv.onCreate()
+
+	newTest := func(name string) (*EnvT, *internal.TestMock) {
+		tMock := &internal.TestMock{TestName: name}
+		e := newEnv(tMock, m, scopes)
+		e.onCreate()
+		return e, tMock
+	}
+
+	fixture := func(e Env, scope CacheScope, key interface{}) {
+		e.CacheResult(func() (*Result, error) {
+			return NewResult(1), nil
+		}, CacheOptions{Scope: scope, CacheKey: key})
+	}
+	errFixture := func(e *EnvT) {
+		runUntilFatal(func() {
+			e.CacheResult(func() (*Result, error) {
+				return nil, errors.New("test")
+			})
+		})
+	}
+
+	e1, t1 := newTest("t1")
+	fixture(e1, ScopePackage, "package")
+	fixture(e1, ScopePackage, "package")
+	fixture(e1, ScopeTest, []int{1, 2})
+	errFixture(e1)
+
+	e2, t2 := newTest("t2")
+	defer t2.CallCleanup()
+	fixture(e2, ScopeTest, nil)
+
+	fixtures := e1.Fixtures()
+	requireEquals(t, 3, len(fixtures))
+
+	requireEquals(t, ScopePackage, fixtures[0].Scope)
+	requireEquals(t, packageScopeName, fixtures[0].ScopeName)
+	requireEquals(t, "package", fixtures[0].Key)
+	requireEquals(t, int64(1), fixtures[0].Hits)
+	requireEquals(t, FixtureValue, fixtures[0].Status)
+	requireTrue(t, fixtures[0].Function != "")
+	requireTrue(t, fixtures[0].File != "")
+	requireFalse(t, fixtures[0].Created.IsZero())
+
+	requireEquals(t, "t1", fixtures[1].ScopeName)
+	requireEquals(t, "[1,2]", fixtures[1].Key)
+	requireEquals(t, int64(0), fixtures[1].Hits)
+
+	requireEquals(t, FixtureError, fixtures[2].Status)
+	requireNotNil(t, fixtures[2].Err)
+
+	requireEquals(t, 4, len(snapshot(m, scopes)))
+
+	t1.CallCleanup()
+	requireEquals(t, 2, len(e2.Fixtures()))
+	requireEquals(t, 2, len(snapshot(m, scopes)))
+}
+
+func TestNewFixtureInfo(t *testing.T) {
+	table := []struct {
+		name   string
+		err    error
+		status FixtureStatus
+	}{
+		{name: "value", err: nil, status: FixtureValue},
+		{name: "error", err: errors.New("test"), status: FixtureError},
+		{name: "skip", err: ErrSkipTest, status: FixtureSkip},
+		{name: "wrapped_skip", err: fmt.Errorf("reason: %w", ErrSkipTest), status: FixtureSkip},
+	}
+
+	for _, test := range table {
+		t.Run(test.name, func(t *testing.T) {
+			info := newFixtureInfo(cacheItem{
+				key: cacheKey{fixture: &fixtureID{function: "f", file: "file.go"}, scope: ScopeTest, scopeName: "t"},
+				val: cacheVal{err: test.err, stat: newCacheStat()},
+			})
+			requireEquals(t, test.status, info.Status)
+			requireEquals(t, test.err, info.Err)
+			requireEquals(t, "f", info.Function)
+			requireEquals(t, "file.go", info.File)
+			requireEquals(t, "t", info.ScopeName)
+		})
+	}
+}
+
+func TestDecodeKeyParams(t *testing.T) {
+	table := []struct {
+		name   string
+		params interface{}
+	}{
+		{name: "nil", params: nil},
+		{name: "plain", params: 123},
+		{name: "json", params: map[string]int{"a": 1}},
+		{name: "keyer", params: cacheKeyTestKeyer{id: 2}},
+	}
+	results := []interface{}{nil, 123, `{"a":1}`, 2}
+
+	for i, test := range table {
+		t.Run(test.name, func(t *testing.T) {
+			params, err := makeKeyParams(test.params)
+			noError(t, err)
+			requireEquals(t, results[i], decodeKeyParams(params))
+		})
+	}
+}
+
+func TestFixtureStatus_String(t *testing.T) {
+	requireEquals(t, "value", FixtureValue.String())
+	requireEquals(t, "error", FixtureError.String())
+	requireEquals(t, "skip", FixtureSkip.String())
+	requireEquals(t, "unknown", FixtureStatus(-1).String())
+}
@@ -0,0 +1,116 @@
+package fixenv
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+)
+
+// LogfFunction is function signature of Logf
+type LogfFunction func(format string, args ...interface{})
+
+// StandaloneOpts is options for env outside of tests
+type StandaloneOpts struct {
+	// Name of env scope, default is same as package scope name (TestMain).
+	// With default name ScopeTest and ScopePackage fixtures share same scope,
+	// set other name if the env used in test binary with fixenv.RunTests.
+	Name string
+
+	// Logf used for log messages from fixtures, default is log.Printf
+	Logf LogfFunction
+
+	// Fatalf called if fixture can't continue work.
+	// Must write log, then exit from goroutine.
+	// Default is panic.
+	Fatalf FatalfFunction
+
+	// SkipNow called if fixture return ErrSkipTest.
+	// Default is panic.
+	SkipNow SkipNowFunction
+
+	// Signals for close env. After receive any of the signals env will be closed
+	// and the process will exit with code 1.
+	// For example: []os.Signal{os.Interrupt, syscall.SIGTERM}
+	Signals []os.Signal
+}
+
+// NewStandalone create env for use fixtures outside of tests: in Example functions,
+// dev servers, data seeders, etc.
+// closeEnv call fixture cleanups and return error if any cleanup panics.
+// closeEnv is safe for call more then once.
+func NewStandalone(opts *StandaloneOpts) (env *EnvT, closeEnv func() error) {
+	if opts == nil {
+		opts = &StandaloneOpts{}
+	}
+
+	t := newVirtualTest(&CreateMainTestEnvOpts{Fatalf: opts.Fatalf, SkipNow: opts.SkipNow})
+	if opts.Name != "" {
+		t.name = opts.Name
+	}
+	if opts.Logf != nil {
+		t.logf = opts.Logf
+	}
+
+	env = New(t)
+
+	var closeOnce sync.Once
+	var closeErr error
+	stopSignals := make(chan struct{})
+	closeEnv = func() error {
+		closeOnce.Do(func() {
+			close(stopSignals)
+			closeErr = t.safeCleanup()
+		})
+		return closeErr
+	}
+
+	if len(opts.Signals) > 0 {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, opts.Signals...)
+		go func() {
+			defer signal.Stop(signals)
+
+			select {
+			case sig := <-signals:
+				t.Logf("fixenv: received signal %v, close env %q", sig, t.Name())
+				if err := closeEnv(); err != nil {
+					t.Logf("fixenv: failed to close env: %v", err)
+				}
+				os.Exit(1)
+			case <-stopSignals:
+			}
+		}()
+	}
+
+	return env, closeEnv
+}
+
+// safeCleanup call all cleanups and convert panics to error
+func (t *virtualTest) safeCleanup() error {
+	t.m.Lock()
+	cleanups := t.cleanups
+	t.cleanups = nil
+	t.m.Unlock()
+
+	var panics []string
+	for i := len(cleanups) - 1; i >= 0; i-- {
+		if rec := callWithRecover(cleanups[i]); rec != nil {
+			panics = append(panics, fmt.Sprint(rec))
+		}
+	}
+	if len(panics) > 0 {
+		return fmt.Errorf("fixenv: cleanup panics: %v", strings.Join(panics, "; "))
+	}
+	return nil
+}
+
+func callWithRecover(f func()) (rec interface{}) {
+	defer func() {
+		rec = recover()
+	}()
+
+	f()
+	return nil
+}
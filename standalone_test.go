@@ -0,0 +1,87 @@
+package fixenv
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestNewStandalone(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		env, closeEnv := NewStandalone(nil)
+		requireEquals(t, packageScopeName, env.T().Name())
+
+		cleanups := 0
+		fixture := func(scope CacheScope) int {
+			return env.CacheResult(func() (*Result, error) {
+				return NewResultWithCleanup(1, func() {
+					cleanups++
+				}), nil
+			}, CacheOptions{Scope: scope}).(int)
+		}
+		requireEquals(t, 1, fixture(ScopeTest))
+		requireEquals(t, 1, fixture(ScopePackage))
+
+		noError(t, closeEnv())
+		requireEquals(t, 2, cleanups)
+		requireNil(t, globalScopeInfo[packageScopeName])
+
+		// second close do nothing
+		noError(t, closeEnv())
+		requireEquals(t, 2, cleanups)
+	})
+
+	t.Run("opts", func(t *testing.T) {
+		var logs []string
+		var fatals []string
+		skipped := 0
+		env, closeEnv := NewStandalone(&StandaloneOpts{
+			Name: "standalone",
+			Logf: func(format string, args ...interface{}) {
+				logs = append(logs, fmt.Sprintf(format, args...))
+			},
+			Fatalf: func(format string, args ...interface{}) {
+				fatals = append(fatals, fmt.Sprintf(format, args...))
+			},
+			SkipNow: func() {
+				skipped++
+			},
+			Signals: []os.Signal{os.Interrupt},
+		})
+		defer func() {
+			noError(t, closeEnv())
+		}()
+
+		requireEquals(t, "standalone", env.T().Name())
+		requireNotNil(t, globalScopeInfo["standalone"])
+
+		env.T().Logf("log %v", 1)
+		requireEquals(t, []string{"log 1"}, logs)
+
+		env.T().Fatalf("fatal %v", 2)
+		requireEquals(t, []string{"fatal 2"}, fatals)
+
+		env.T().SkipNow()
+		requireEquals(t, 1, skipped)
+		requireTrue(t, env.T().Skipped())
+	})
+
+	t.Run("cleanup_panic", func(t *testing.T) {
+		env, closeEnv := NewStandalone(&StandaloneOpts{Name: "standalone"})
+
+		cleanups := 0
+		for _, key := range []string{"first", "second"} {
+			env.CacheResult(func() (*Result, error) {
+				return NewResultWithCleanup(nil, func() {
+					cleanups++
+					panic("test panic")
+				}), nil
+			}, CacheOptions{CacheKey: key})
+		}
+
+		err := closeEnv()
+		isError(t, err)
+		requireEquals(t, 2, cleanups)
+		requireNil(t, globalScopeInfo["standalone"])
+	})
+}
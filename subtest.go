@@ -0,0 +1,59 @@
+package fixenv
+
+import "testing"
+
+type testRunner interface {
+	Run(name string, f func(t *testing.T)) bool
+}
+
+type benchmarkRunner interface {
+	Run(name string, f func(b *testing.B)) bool
+}
+
+// Run runs f as subtest of env test with name (t.Run or b.Run) and pass new env of the subtest to f.
+// The subtest env linked to parent env, see Parent.
+// Env test must be *testing.T or *testing.B or implement same Run method.
+//
+// Custom envs, which embed EnvT, can wrap child env for keep own fields:
+//
+//	func (e *ProjectEnv) Run(name string, f func(e *ProjectEnv)) bool {
+//		return e.EnvT.Run(name, func(child *fixenv.EnvT) {
+//			f(&ProjectEnv{EnvT: child})
+//		})
+//	}
+func (e *EnvT) Run(name string, f func(e *EnvT)) bool {
+	run := func(t T) {
+		child := newEnv(t, e.m, e.scopes)
+		child.parent = e
+		child.onCreate()
+		f(child)
+	}
+
+	switch parentT := e.t.(type) {
+	case testRunner:
+		return parentT.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	case benchmarkRunner:
+		return parentT.Run(name, func(b *testing.B) {
+			run(b)
+		})
+	default:
+		e.t.Fatalf("fixenv: test %q doesn't support subtests", e.t.Name())
+		return false
+	}
+}
+
+// root return first env of Run chain
+func (e *EnvT) root() *EnvT {
+	root := e
+	for root.parent != nil {
+		root = root.parent
+	}
+	return root
+}
+
+// Parent return env of parent test, if the env created by Run. Return nil for other envs.
+func (e *EnvT) Parent() *EnvT {
+	return e.parent
+}
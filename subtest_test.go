@@ -0,0 +1,61 @@
+package fixenv
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/rekby/fixenv/internal"
+)
+
+func TestEnv_Run(t *testing.T) {
+	parent := New(t)
+	requireNil(t, parent.Parent())
+
+	fixture := func(e Env, scope CacheScope) int {
+		return e.CacheResult(func() (*Result, error) {
+			return NewResult(rand.Int()), nil
+		}, CacheOptions{Scope: scope}).(int)
+	}
+
+	parentValue := fixture(parent, ScopeTestAndSubtests)
+	parentTestValue := fixture(parent, ScopeTest)
+
+	called := false
+	res := parent.Run("subtest", func(e *EnvT) {
+		called = true
+		requireEquals(t, parent, e.Parent())
+		requireEquals(t, t.Name()+"/subtest", e.T().Name())
+
+		requireEquals(t, parentValue, fixture(e, ScopeTestAndSubtests))
+		requireNotEquals(t, parentTestValue, fixture(e, ScopeTest))
+
+		e.Run("nested", func(e *EnvT) {
+			requireEquals(t, parentValue, fixture(e, ScopeTestAndSubtests))
+		})
+	})
+	requireTrue(t, res)
+	requireTrue(t, called)
+}
+
+func TestEnv_RunBenchmark(t *testing.T) {
+	called := false
+	testing.Benchmark(func(b *testing.B) {
+		// testing.Benchmark run sub-benchmarks with same empty name, then parent env is not registered
+		parent := newEnv(b, &sync.Mutex{}, make(map[string]*scopeInfo))
+		parent.Run("sub", func(e *EnvT) {
+			called = true
+			requireEquals(t, parent, e.Parent())
+		})
+	})
+	requireTrue(t, called)
+}
+
+func TestEnv_RunNotSupported(t *testing.T) {
+	tMock := &internal.TestMock{TestName: "mock", SkipGoexit: true}
+	e := newTestEnv(tMock)
+	requireFalse(t, e.Run("sub", func(e *EnvT) {
+		t.Fatal("must not be called")
+	}))
+	requireEquals(t, 1, len(tMock.Fatals))
+}
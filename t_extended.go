@@ -0,0 +1,139 @@
+package fixenv
+
+import (
+	"os"
+	"time"
+)
+
+// Optional extensions of T interface. testing.T implement all of them,
+// but other T implementations (virtual test for package scope, mocks) may not.
+// Fixtures can use public helpers below, instead of type assertion to testing.TB.
+type (
+	// failedT is optional extension of T, for detect failed tests.
+	failedT interface {
+		Failed() bool
+	}
+
+	// failT is optional extension of T, for mark test failed without stop it.
+	failT interface {
+		Fail()
+	}
+
+	helperT interface {
+		Helper()
+	}
+
+	deadlineT interface {
+		Deadline() (deadline time.Time, ok bool)
+	}
+
+	setenvT interface {
+		Setenv(key, value string)
+	}
+
+	tempDirT interface {
+		TempDir() string
+	}
+
+	parallelT interface {
+		Parallel()
+	}
+)
+
+// Failed reports whether the test of env has failed.
+// It return false if T of the env has no Failed method.
+func Failed(env Env) bool {
+	return isFailed(env.T())
+}
+
+// Helper return Helper function of the test, or no-op function if T of the env has no Helper method.
+// Call result from the function, which must be marked as helper:
+//
+//	func myFixture(e fixenv.Env) {
+//		fixenv.Helper(e)()
+//		...
+//	}
+func Helper(env Env) func() {
+	if ht, ok := env.T().(helperT); ok {
+		return ht.Helper
+	}
+	return func() {}
+}
+
+// Deadline reports the time at which the test binary will have exceeded the timeout.
+// ok is false if T of the env has no Deadline method or the test has no deadline.
+func Deadline(env Env) (deadline time.Time, ok bool) {
+	if dt, isDeadlineT := env.T().(deadlineT); isDeadlineT {
+		return dt.Deadline()
+	}
+	return time.Time{}, false
+}
+
+// Setenv set environment variable and restore it on test cleanup.
+// It use Setenv of T if available, or os.Setenv otherwise.
+func Setenv(env Env, key, value string) {
+	t := env.T()
+	if st, ok := t.(setenvT); ok {
+		st.Setenv(key, value)
+		return
+	}
+
+	prevValue, prevExist := os.LookupEnv(key)
+	if err := os.Setenv(key, value); err != nil {
+		t.Fatalf("fixenv: failed to set environment variable %q: %v", key, err)
+		return
+	}
+	t.Cleanup(func() {
+		if prevExist {
+			_ = os.Setenv(key, prevValue)
+		} else {
+			_ = os.Unsetenv(key)
+		}
+	})
+}
+
+// TempDir return new temporary directory, which will be removed on test cleanup.
+// It use TempDir of T if available, or create the directory itself otherwise.
+func TempDir(env Env) string {
+	t := env.T()
+	if tt, ok := t.(tempDirT); ok {
+		return tt.TempDir()
+	}
+
+	dir, err := os.MkdirTemp("", "fixenv-")
+	if err != nil {
+		t.Fatalf("fixenv: failed to create temp dir: %v", err)
+		return ""
+	}
+	t.Cleanup(func() {
+		_ = os.RemoveAll(dir)
+	})
+	return dir
+}
+
+// Parallel signals that the test is to be run in parallel, if T of the env support it.
+// It return true if Parallel of T was called.
+func Parallel(env Env) bool {
+	if pt, ok := env.T().(parallelT); ok {
+		pt.Parallel()
+		return true
+	}
+	return false
+}
+
+// isFailed return true if t support Failed method and the test failed
+func isFailed(t T) bool {
+	ft, ok := t.(failedT)
+	return ok && ft.Failed()
+}
+
+// failTest log message and mark test failed without stop.
+// It calls Fatalf if T has no Fail method.
+func failTest(t T, format string, args ...interface{}) {
+	if ft, ok := t.(failT); ok {
+		t.Logf(format, args...)
+		ft.Fail()
+		return
+	}
+	t.Fatalf(format, args...)
+}
@@ -0,0 +1,76 @@
+package fixenv
+
+import (
+	"os"
+	"testing"
+
+	"github.com/rekby/fixenv/internal"
+)
+
+func TestExtendedT(t *testing.T) {
+	t.Run("testing_t", func(t *testing.T) {
+		e := newTestEnv(t)
+
+		requireFalse(t, Failed(e))
+
+		deadline, ok := Deadline(e)
+		expectedDeadline, expectedOk := t.Deadline()
+		requireEquals(t, expectedDeadline, deadline)
+		requireEquals(t, expectedOk, ok)
+
+		Setenv(e, "FIXENV_TEST_EXTENDED_T", "val")
+		requireEquals(t, "val", os.Getenv("FIXENV_TEST_EXTENDED_T"))
+
+		dir := TempDir(e)
+		_, err := os.Stat(dir)
+		noError(t, err)
+	})
+
+	t.Run("testing_t_parallel", func(t *testing.T) {
+		e := newTestEnv(t)
+		requireTrue(t, Parallel(e))
+	})
+
+	t.Run("mock", func(t *testing.T) {
+		tMock := &internal.TestMock{TestName: "mock"}
+		e := newTestEnv(tMock)
+
+		requireFalse(t, Failed(e))
+		tMock.Fail()
+		requireTrue(t, Failed(e))
+
+		helpers := tMock.HelperCount
+		Helper(e)()
+		requireEquals(t, helpers+1, tMock.HelperCount)
+
+		_, ok := Deadline(e)
+		requireFalse(t, ok)
+
+		const envName = "FIXENV_TEST_EXTENDED_T_MOCK"
+		prevValue, prevExist := os.LookupEnv(envName)
+		Setenv(e, envName, "val")
+		requireEquals(t, "val", os.Getenv(envName))
+
+		dir := TempDir(e)
+		_, err := os.Stat(dir)
+		noError(t, err)
+
+		requireFalse(t, Parallel(e))
+
+		tMock.CallCleanup()
+		value, exist := os.LookupEnv(envName)
+		requireEquals(t, prevValue, value)
+		requireEquals(t, prevExist, exist)
+		_, err = os.Stat(dir)
+		requireTrue(t, os.IsNotExist(err))
+	})
+
+	t.Run("without_helper", func(t *testing.T) {
+		// T interface hide Helper method of the mock
+		tMock := &internal.TestMock{TestName: "mock"}
+		e := newTestEnv(struct{ T }{tMock})
+
+		Helper(e)()
+		requireEquals(t, 0, tMock.HelperCount)
+	})
+}
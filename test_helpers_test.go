@@ -1,7 +1,7 @@
 package fixenv
 
 import (
-	"encoding/json"
+	"os"
 	"reflect"
 	"testing"
 )
@@ -82,25 +82,22 @@ func requirePanic(t *testing.T, f func()) {
 	t.Fatal("the function must raise panic")
 }
 
-func requireJSONEquals(t *testing.T, v1 string, v2 string) {
-	t.Helper()
-	var vj1 interface{}
-	var vj2 interface{}
-
-	err := json.Unmarshal([]byte(v1), &vj1)
-	noError(t, err)
-
-	err = json.Unmarshal([]byte(v2), &vj2)
-	noError(t, err)
-
-	data1, err := json.Marshal(vj1)
-	noError(t, err)
-
-	data2, err := json.Marshal(vj2)
-	noError(t, err)
-
-	normalized1 := string(data1)
-	normalized2 := string(data2)
+// testCacheKey return cache key with name as params
+func testCacheKey(name string) cacheKey {
+	return cacheKey{params: name}
+}
 
-	requireEquals(t, normalized1, normalized2)
+// setenvForTest set environment variable and restore previous value on test cleanup.
+// It used instead of t.Setenv, which not exists in go 1.16.
+func setenvForTest(t *testing.T, name, value string) {
+	t.Helper()
+	prevValue, prevExist := os.LookupEnv(name)
+	noError(t, os.Setenv(name, value))
+	t.Cleanup(func() {
+		if prevExist {
+			_ = os.Setenv(name, prevValue)
+		} else {
+			_ = os.Unsetenv(name)
+		}
+	})
 }